@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shanmukasadhu/GOImageScrape/scraper"
+)
+
+// Config holds the command line options for a scrape run
+type Config struct {
+	SitemapURL          string
+	Sitemaps            []string
+	URLsFile            string
+	Site                string
+	SitemapOrder        string
+	IgnoreTrailingSlash bool
+	CrawlSeed           string
+	MaxDepth            int
+	CrossHost           bool
+	OutputPath          string
+	Format              string
+	Fields              []string
+	OnlyWithImages      bool
+	Sort                bool
+	Concurrency         int
+	MaxConcurrency      int
+	BreakerFailures     int
+	BreakerCooldown     time.Duration
+	Adaptive            bool
+	AdaptiveStart       int
+	AdaptiveMin         int
+	Include             *regexp.Regexp
+	Exclude             *regexp.Regexp
+	Limit               int
+	Sample              bool
+	DryRun              bool
+	MaxRedirects        int
+	MaxAttempts         int
+	RetryDelay          time.Duration
+	RateLimit           float64
+	RateBurst           int
+	Delay               time.Duration
+	StartJitter         time.Duration
+	Dedupe              string
+	DedupeKey           string
+	DetectDuplicates    bool
+	VerifyImages        bool
+	ProbeImages         bool
+	PreferredTypes      []string
+	Video               bool
+	Audio               bool
+	Normalizer          *scraper.URLNormalizer
+	Filter              scraper.ImageFilter
+	DownloadDir         string
+	MaxImageSize        int64
+	MaxPageSize         int64
+	MaxContentLength    int64
+	RespectRobots       bool
+	ParseErrorPages     bool
+	Headers             http.Header
+	Username            string
+	Password            string
+	BearerToken         string
+	UserAgents          []string
+	UserAgentRotation   string
+	CookieJar           bool
+	CachePath           string
+	HARPath             string
+	Timeout             time.Duration
+	MaxDuration         time.Duration
+	ConnectTimeout      time.Duration
+	HeaderTimeout       time.Duration
+	Proxy               *url.URL
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	MaxConnsPerHost     int
+	ForceHTTP1          bool
+	TLSConfig           *tls.Config
+	LogLevel            slog.Level
+	Progress            bool
+}
+
+// parseFlags builds a Config from the command line arguments (without the
+// program name). Usage is printed for -h and for invalid flags.
+func parseFlags(args []string) (Config, error) {
+	var cfg Config
+	fs := flag.NewFlagSet("GOImageScrape", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: GOImageScrape [flags]\n\nScrapes image URLs from every page listed in a sitemap or URL file.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&cfg.SitemapURL, "sitemap", "https://www.espn.com/googlenewssitemap", "sitemap URL or local file to scrape")
+	fs.Var((*listFlag)(&cfg.Sitemaps), "sitemaps", "comma separated sitemap URLs or local files to scrape together (repeatable)")
+	fs.StringVar(&cfg.URLsFile, "urls-file", "", "file of newline separated URLs to scrape instead of, or as well as, the sitemap")
+	fs.StringVar(&cfg.Site, "site", "", "site URL whose robots.txt Sitemap entries are scraped")
+	fs.StringVar(&cfg.SitemapOrder, "sitemap-order", "", "scrape sitemap pages by priority (highest first) or lastmod (newest first) instead of sitemap order")
+	fs.BoolVar(&cfg.IgnoreTrailingSlash, "ignore-trailing-slash", false, "treat sitemap URLs that differ only by a trailing slash as the same page")
+	fs.StringVar(&cfg.CrawlSeed, "crawl", "", "crawl by following links from this URL instead of reading a sitemap")
+	fs.IntVar(&cfg.MaxDepth, "max-depth", 2, "with -crawl, how many links away from the seed to follow")
+	fs.BoolVar(&cfg.CrossHost, "cross-host", false, "with -crawl, also follow links to other hosts")
+	fs.StringVar(&cfg.OutputPath, "out", "", "output file path, or - for stdout (default image_results.<format extension>)")
+	fs.StringVar(&cfg.Format, "format", "text", "output format: text, json, ndjson or csv")
+	fields := fs.String("fields", "", "comma separated result fields to write, by JSON name, e.g. url,image_urls (default all; text has url, status_code, title, meta_description and image_urls, csv the same but title)")
+	fs.BoolVar(&cfg.OnlyWithImages, "only-with-images", false, "leave pages without any images out of the output")
+	fs.BoolVar(&cfg.Sort, "sort", false, "write the pages sorted by URL once all are scraped, instead of as they finish, so runs over the same pages can be diffed; timings are written as 0 unless -fields names them")
+	fs.IntVar(&cfg.Concurrency, "concurrency", scraper.DefaultConcurrency, "number of concurrent requests")
+	fs.IntVar(&cfg.MaxConcurrency, "max-concurrency", scraper.DefaultMaxConcurrency, "upper limit for -concurrency")
+	fs.BoolVar(&cfg.Adaptive, "adaptive", false, "adjust the number of concurrent requests to the error rate, up to -concurrency: more while requests succeed, half as many on 429s, 5xxs and failures")
+	fs.IntVar(&cfg.AdaptiveStart, "adaptive-start", 4, "with -adaptive, the number of concurrent requests to start with")
+	fs.IntVar(&cfg.AdaptiveMin, "adaptive-min", 1, "with -adaptive, the fewest concurrent requests to back off to")
+	include := fs.String("include", "", "only scrape URLs matching this regular expression")
+	exclude := fs.String("exclude", "", "skip URLs matching this regular expression (wins over -include)")
+	fs.IntVar(&cfg.Limit, "limit", 0, "scrape at most this many URLs (0 for no limit)")
+	fs.BoolVar(&cfg.Sample, "sample", false, "with -limit, pick a random sample of URLs instead of the first ones")
+	fs.Float64Var(&cfg.RateLimit, "rate", 0, "maximum requests per second to each host (0 for no limit)")
+	fs.IntVar(&cfg.RateBurst, "burst", 1, "number of requests to a host allowed at once before -rate applies")
+	fs.IntVar(&cfg.BreakerFailures, "breaker-failures", 0, "after this many failed requests in a row to a host, retries included, skip its remaining URLs for -breaker-cooldown (0 to never stop)")
+	fs.DurationVar(&cfg.BreakerCooldown, "breaker-cooldown", time.Minute, "with -breaker-failures, how long to skip a failing host before trying it again")
+	fs.DurationVar(&cfg.Delay, "delay", 0, "minimum time between requests to the same host, overriding any robots.txt Crawl-delay (0 to use robots.txt)")
+	fs.DurationVar(&cfg.StartJitter, "start-jitter", 0, "longest random wait before each worker's first request, so a scrape doesn't open with a burst (0 for none)")
+	fs.DurationVar(&cfg.Timeout, "timeout", scraper.DefaultTimeout, "time limit for each request, including reading the body")
+	fs.DurationVar(&cfg.MaxDuration, "max-duration", 0, "time limit for the whole scrape, unlike -timeout; pages not scraped by then are skipped and the rest written out (0 for no limit)")
+	fs.DurationVar(&cfg.ConnectTimeout, "connect-timeout", 0, "time limit for connecting to a server (0 for no separate limit)")
+	fs.DurationVar(&cfg.HeaderTimeout, "header-timeout", 0, "time limit for receiving response headers (0 for no separate limit)")
+	fs.IntVar(&cfg.MaxIdleConns, "max-idle-conns", 0, "most keep-alive connections to keep open across all hosts (0 for no limit)")
+	fs.IntVar(&cfg.MaxIdleConnsPerHost, "max-idle-conns-per-host", scraper.DefaultMaxIdleConnsPerHost, "most keep-alive connections to keep open to each host")
+	fs.DurationVar(&cfg.IdleConnTimeout, "idle-conn-timeout", scraper.DefaultIdleConnTimeout, "how long an unused keep-alive connection is kept open")
+	insecure := fs.Bool("insecure", false, "don't verify TLS certificates, e.g. for internal hosts with self-signed ones (unsafe)")
+	caCert := fs.String("ca-cert", "", "PEM file of extra certificate authorities to trust for TLS")
+	fs.BoolVar(&cfg.ForceHTTP1, "http1", false, "always use HTTP/1.1, even with servers that offer HTTP/2")
+	fs.IntVar(&cfg.MaxConnsPerHost, "max-conns-per-host", 0, "most connections open to each host at once; further requests wait for one (0 for no limit)")
+	proxy := fs.String("proxy", "", "proxy URL (http://, https:// or socks5://); defaults to the HTTP_PROXY environment variables")
+	fs.IntVar(&cfg.MaxRedirects, "max-redirects", 10, "maximum number of redirects to follow per request")
+	fs.IntVar(&cfg.MaxAttempts, "max-attempts", scraper.DefaultMaxAttempts, "how many times to try a request that times out, loses its connection or gets a 429 or 5xx (1 for no retries)")
+	fs.DurationVar(&cfg.RetryDelay, "retry-delay", scraper.DefaultRetryDelay, "wait before the first retry, doubled for each one after")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "print the URLs that would be scraped and exit without fetching them")
+	fs.StringVar(&cfg.Dedupe, "dedupe", "page", "remove repeated image URLs: page, global (across all pages, also dropping pages with the same canonical URL) or none; all but none also drop pages that redirect to a page already scraped")
+	fs.StringVar(&cfg.DedupeKey, "dedupe-key", "url", "what makes two images the same when removing repeats: url, no-query (the URL without its query string) or content (the image file, fetching each one)")
+	fs.BoolVar(&cfg.DetectDuplicates, "detect-duplicates", false, "flag pages whose set of images is the same as an earlier page's")
+	fs.BoolVar(&cfg.VerifyImages, "verify-images", false, "after scraping, request every image to record its status and report broken ones (one extra request per image)")
+	fs.BoolVar(&cfg.ProbeImages, "probe-images", false, "after scraping, read the start of every image to record its format and dimensions (one extra request per image)")
+	includeExt := fs.String("include-ext", "", "comma separated image extensions to keep, e.g. .jpg,.png,.webp")
+	excludeExt := fs.String("exclude-ext", "", "comma separated image extensions to drop, e.g. .svg,.gif")
+	fs.BoolVar(&cfg.Filter.DropDataURIs, "drop-data-uris", false, "drop inline data: images")
+	fs.BoolVar(&cfg.Filter.SameHost, "same-host", false, "keep only images served from the same host as their page")
+	fs.IntVar(&cfg.Filter.MinWidth, "min-width", 0, "drop images whose img tag declares a width under this many pixels")
+	fs.IntVar(&cfg.Filter.MinHeight, "min-height", 0, "drop images whose img tag declares a height under this many pixels")
+	fs.BoolVar(&cfg.Filter.RequireDimensions, "require-dimensions", false, "with -min-width or -min-height, also drop images that don't declare that dimension")
+	imageHosts := fs.String("image-hosts", "", "comma separated hosts (and their subdomains) to keep images from, in addition to the page host with -same-host")
+	normalize := fs.Bool("normalize", false, "normalize image URLs before removing duplicates: lowercase the host, drop fragments and strip -strip-params")
+	stripParams := fs.String("strip-params", scraper.DefaultTrackingParams, "with -normalize, comma separated query parameters to strip; a trailing * matches a prefix")
+	mediaTypes := fs.String("media-types", "image", "comma separated media to extract: image, video and audio (images are always extracted)")
+	preferFormats := fs.String("prefer-formats", "", "comma separated formats to pick from <picture> elements in order of preference, e.g. avif,webp")
+	fs.StringVar(&cfg.DownloadDir, "download-dir", "", "download the images into this directory")
+	fs.Int64Var(&cfg.MaxImageSize, "max-image-size", scraper.DefaultMaxImageSize, "largest image in bytes to download")
+	fs.Int64Var(&cfg.MaxPageSize, "max-page-size", scraper.DefaultMaxPageSize, "most bytes of each page to parse; the rest is ignored (0 for no limit)")
+	fs.Int64Var(&cfg.MaxContentLength, "max-content-length", 0, "skip pages whose Content-Length is over this many bytes without reading them (0 for no limit)")
+	fs.BoolVar(&cfg.ParseErrorPages, "parse-error-pages", false, "parse pages served with a status outside 2xx instead of counting them as failed")
+	fs.BoolVar(&cfg.RespectRobots, "robots", true, "skip URLs disallowed by the site's robots.txt")
+	cfg.Headers = http.Header{}
+	fs.Var(headerFlag(cfg.Headers), "header", "extra request header as \"Name: value\" (repeatable)")
+	fs.Var(cookieFlag(cfg.Headers), "cookie", "cookie to send as \"name=value\" (repeatable)")
+	fs.Var((*stringsFlag)(&cfg.UserAgents), "user-agent", "User-Agent to pick from instead of the built-in list (repeatable)")
+	fs.StringVar(&cfg.UserAgentRotation, "rotate-ua", "random", "how each request picks its User-Agent: random, round-robin or fixed (always the first)")
+	extendAgents := fs.Bool("extend-user-agents", false, "add the -user-agent values to the built-in list instead of replacing it")
+	fs.StringVar(&cfg.Username, "user", "", "username for HTTP Basic Auth")
+	fs.StringVar(&cfg.Password, "pass", "", "password for HTTP Basic Auth")
+	fs.StringVar(&cfg.BearerToken, "bearer", "", "token to send as \"Authorization: Bearer <token>\" (can't be used with -user)")
+	fs.BoolVar(&cfg.CookieJar, "cookie-jar", false, "keep cookies set by servers between requests")
+	fs.StringVar(&cfg.HARPath, "har", "", "also record every page request and response into this HAR file")
+	fs.StringVar(&cfg.CachePath, "cache", "", "file caching page validators so unchanged pages aren't fetched again on later runs")
+	logLevel := fs.String("log-level", "info", "minimum level of log messages: debug, info, warn or error")
+	quiet := fs.Bool("quiet", false, "only log errors")
+	fs.BoolVar(&cfg.Progress, "progress", false, "show how many URLs are done on stderr")
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// Validate and derive the remaining settings, reporting errors the same
+	// way the flag package reports malformed flags
+	cfg.Filter.Include = scraper.ParseExtensions(*includeExt)
+	cfg.Filter.Exclude = scraper.ParseExtensions(*excludeExt)
+	cfg.Filter.Hosts = scraper.ParseHosts(*imageHosts)
+	cfg.PreferredTypes = scraper.ParseImageTypes(*preferFormats)
+	cfg.Sitemaps = scraper.MergeURLs(cfg.Sitemaps)
+	if *normalize {
+		cfg.Normalizer = &scraper.URLNormalizer{StripParams: scraper.ParseParamList(*stripParams)}
+	}
+
+	var err error
+	if cfg.Video, cfg.Audio, err = scraper.ParseMediaTypes(*mediaTypes); err != nil {
+		return Config{}, usageError(fs, err)
+	}
+	if cfg.Include, err = compilePattern("include", *include); err != nil {
+		return Config{}, usageError(fs, err)
+	}
+	if cfg.Exclude, err = compilePattern("exclude", *exclude); err != nil {
+		return Config{}, usageError(fs, err)
+	}
+
+	if *proxy != "" {
+		proxyURL, err := parseProxy(*proxy)
+		if err != nil {
+			return Config{}, usageError(fs, err)
+		}
+		cfg.Proxy = proxyURL
+	}
+
+	if *insecure || *caCert != "" {
+		tlsConfig, err := newTLSConfig(*insecure, *caCert)
+		if err != nil {
+			return Config{}, usageError(fs, err)
+		}
+		cfg.TLSConfig = tlsConfig
+	}
+
+	if *extendAgents && len(cfg.UserAgents) > 0 {
+		cfg.UserAgents = append(append([]string{}, scraper.DefaultUserAgents...), cfg.UserAgents...)
+	}
+
+	// A sitemap list, URL file, site or crawl seed replaces the default
+	// sitemap unless -sitemap was also given
+	if (cfg.URLsFile != "" || cfg.Site != "" || cfg.CrawlSeed != "" || len(cfg.Sitemaps) > 0) && !flagWasSet(fs, "sitemap") {
+		cfg.SitemapURL = ""
+	}
+	if cfg.CrawlSeed != "" {
+		if cfg.DryRun {
+			return Config{}, usageError(fs, errors.New("-dry-run can't be used with -crawl, which finds pages by fetching them"))
+		}
+		if flagWasSet(fs, "sitemap") || len(cfg.Sitemaps) > 0 || cfg.URLsFile != "" || cfg.Site != "" {
+			return Config{}, usageError(fs, errors.New("-crawl can't be combined with -sitemap, -sitemaps, -urls-file or -site"))
+		}
+	}
+
+	if cfg.Filter.RequireDimensions && cfg.Filter.MinWidth <= 0 && cfg.Filter.MinHeight <= 0 {
+		return Config{}, usageError(fs, errors.New("-require-dimensions needs -min-width or -min-height"))
+	}
+
+	if cfg.MaxAttempts < 1 {
+		return Config{}, usageError(fs, errors.New("-max-attempts must be at least 1"))
+	}
+	if cfg.RetryDelay <= 0 {
+		return Config{}, usageError(fs, errors.New("-retry-delay must be positive"))
+	}
+
+	if cfg.BearerToken != "" && cfg.Username != "" {
+		return Config{}, usageError(fs, errors.New("-bearer can't be combined with -user, both set the Authorization header"))
+	}
+
+	level, err := parseLogLevel(*logLevel)
+	if err != nil {
+		return Config{}, usageError(fs, err)
+	}
+	cfg.LogLevel = level
+	if *quiet {
+		cfg.LogLevel = slog.LevelError
+	}
+	if _, ok := scraper.OutputFormats[cfg.Format]; !ok {
+		return Config{}, usageError(fs, fmt.Errorf("unknown output format %q", cfg.Format))
+	}
+	if cfg.Fields, err = scraper.ParseFields(*fields); err != nil {
+		return Config{}, usageError(fs, err)
+	}
+	if err := scraper.OutputFormats[cfg.Format].CheckFields(cfg.Fields); err != nil {
+		return Config{}, usageError(fs, fmt.Errorf("-format %s: %w", cfg.Format, err))
+	}
+	if cfg.Dedupe != "page" && cfg.Dedupe != "global" && cfg.Dedupe != "none" {
+		return Config{}, usageError(fs, fmt.Errorf("unknown dedupe mode %q", cfg.Dedupe))
+	}
+	if _, ok := dedupeKeys[cfg.DedupeKey]; !ok {
+		return Config{}, usageError(fs, fmt.Errorf("unknown dedupe key %q", cfg.DedupeKey))
+	}
+	if !scraper.UserAgentRotations[cfg.UserAgentRotation] {
+		return Config{}, usageError(fs, fmt.Errorf("unknown User-Agent rotation %q", cfg.UserAgentRotation))
+	}
+	if !scraper.SitemapOrders[cfg.SitemapOrder] {
+		return Config{}, usageError(fs, fmt.Errorf("unknown sitemap order %q", cfg.SitemapOrder))
+	}
+	if cfg.OutputPath == "" {
+		cfg.OutputPath = "image_results." + scraper.OutputFormats[cfg.Format].Extension
+	}
+	return cfg, nil
+}
+
+// dedupeKeys builds the image dedupe key for each -dedupe-key value
+var dedupeKeys = map[string]func(ctx context.Context, s *scraper.Scraper) func(string) string{
+	"url":      func(context.Context, *scraper.Scraper) func(string) string { return scraper.URLKey },
+	"no-query": func(context.Context, *scraper.Scraper) func(string) string { return scraper.URLWithoutQuery },
+	"content":  func(ctx context.Context, s *scraper.Scraper) func(string) string { return s.ContentHashKey(ctx) },
+}
+
+// compilePattern compiles the regular expression given to the named flag; an
+// empty pattern gives nil
+func compilePattern(name, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s pattern: %w", name, err)
+	}
+	return re, nil
+}
+
+// newTLSConfig builds the TLS settings for -insecure and -ca-cert. The
+// certificates in caFile are trusted on top of the system ones.
+func newTLSConfig(insecure bool, caFile string) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading -ca-cert: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// parseProxy validates a -proxy value
+func parseProxy(rawURL string) (*url.URL, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, want http, https or socks5", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", rawURL)
+	}
+	return proxyURL, nil
+}
+
+// stringsFlag collects the values of a repeatable flag, ignoring blank ones
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *stringsFlag) Set(value string) error {
+	if value = strings.TrimSpace(value); value != "" {
+		*f = append(*f, value)
+	}
+	return nil
+}
+
+// listFlag collects the comma separated values of a repeatable flag
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+	return nil
+}
+
+// headerFlag collects repeated -header "Name: value" flags
+type headerFlag http.Header
+
+func (h headerFlag) String() string { return "" }
+
+func (h headerFlag) Set(value string) error {
+	name, val, found := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return fmt.Errorf("header %q is not in \"Name: value\" form", value)
+	}
+	http.Header(h).Add(name, strings.TrimSpace(val))
+	return nil
+}
+
+// cookieFlag collects repeated -cookie "name=value" flags into the Cookie header
+type cookieFlag http.Header
+
+func (c cookieFlag) String() string { return "" }
+
+func (c cookieFlag) Set(value string) error {
+	name, val, found := strings.Cut(value, "=")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return fmt.Errorf("cookie %q is not in \"name=value\" form", value)
+	}
+	cookie := (&http.Cookie{Name: name, Value: strings.TrimSpace(val)}).String()
+	if existing := http.Header(c).Get("Cookie"); existing != "" {
+		cookie = existing + "; " + cookie
+	}
+	http.Header(c).Set("Cookie", cookie)
+	return nil
+}
+
+// flagWasSet reports whether the named flag was given on the command line
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// usageError prints err followed by the usage message and returns err
+func usageError(fs *flag.FlagSet, err error) error {
+	fmt.Fprintln(fs.Output(), err)
+	fs.Usage()
+	return err
+}
+
+// mustParseFlags parses os.Args, exiting on -h or invalid flags. The error
+// and usage have already been printed by parseFlags.
+func mustParseFlags() Config {
+	cfg, err := parseFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	return cfg
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"INFO", slog.LevelInfo},
+		{"warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+	for _, tt := range tests {
+		level, err := parseLogLevel(tt.value)
+		if err != nil || level != tt.want {
+			t.Errorf("parseLogLevel(%q) = %v, %v, want %v", tt.value, level, err, tt.want)
+		}
+	}
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("parseLogLevel of an unknown level returned no error")
+	}
+}
+
+func TestNewLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&buf, slog.LevelWarn)
+	log.Info("scraping page", "url", "https://example.com/")
+	log.Warn("retrying page", "url", "https://example.com/")
+
+	out := buf.String()
+	if strings.Contains(out, "scraping page") {
+		t.Errorf("info message was logged at warn level:\n%s", out)
+	}
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, `msg="retrying page" url=https://example.com/`) {
+		t.Errorf("warn message missing or not in key=value form:\n%s", out)
+	}
+}
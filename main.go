@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/cookiejar"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/shanmukasadhu/GOImageScrape/scraper"
+)
+
+func main() {
+	if err := run(mustParseFlags()); err != nil {
+		fatal("Scrape failed", "err", err)
+	}
+}
+
+// politeConcurrency is the most concurrent requests made without a warning
+// when nothing else limits the request rate
+const politeConcurrency = 20
+
+// run scrapes and writes out everything cfg asks for. Failing to read the
+// URL sources or to create the output is returned as an error; individual
+// pages that fail are logged and counted in the summary instead.
+func run(cfg Config) error {
+	logger = newLogger(logOutput, cfg.LogLevel)
+	scraper.Logger = logger
+	if cfg.TLSConfig != nil && cfg.TLSConfig.InsecureSkipVerify {
+		logger.Warn("-insecure is set: TLS certificates are NOT verified, so any server can impersonate the sites being scraped")
+	}
+	if cfg.Concurrency > politeConcurrency && cfg.RateLimit <= 0 && cfg.Delay <= 0 && !cfg.DryRun {
+		logger.Warn("High -concurrency without -rate or -delay may get the scraper blocked", "concurrency", cfg.Concurrency, "polite_limit", politeConcurrency)
+	}
+
+	// Create a Scraper with a DefaultParser instance. It also serves the package
+	// level helpers such as DownloadImages.
+	s := &scraper.Scraper{
+		Concurrency:         cfg.Concurrency,
+		MaxConcurrency:      cfg.MaxConcurrency,
+		Logger:              logger,
+		UserAgents:          cfg.UserAgents,
+		UserAgentRotation:   cfg.UserAgentRotation,
+		Headers:             cfg.Headers,
+		Username:            cfg.Username,
+		Password:            cfg.Password,
+		BearerToken:         cfg.BearerToken,
+		Timeout:             cfg.Timeout,
+		ConnectTimeout:      cfg.ConnectTimeout,
+		HeaderTimeout:       cfg.HeaderTimeout,
+		Proxy:               cfg.Proxy,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		TLSConfig:           cfg.TLSConfig,
+		ForceHTTP1:          cfg.ForceHTTP1,
+		IgnoreTrailingSlash: cfg.IgnoreTrailingSlash,
+		StartJitter:         cfg.StartJitter,
+		ParseErrorPages:     cfg.ParseErrorPages,
+		IgnoreRobots:        !cfg.RespectRobots,
+		Delay:               cfg.Delay,
+		MaxRedirects:        cfg.MaxRedirects,
+		MaxAttempts:         cfg.MaxAttempts,
+		RetryDelay:          cfg.RetryDelay,
+		MaxPageSize:         cfg.MaxPageSize,
+		MaxContentLength:    cfg.MaxContentLength,
+		MaxImageSize:        cfg.MaxImageSize,
+	}
+	// -max-redirects 0 follows no redirects and -max-page-size 0 parses
+	// whole pages, where the fields' zero means the default
+	if cfg.MaxRedirects == 0 {
+		s.MaxRedirects = -1
+	}
+	if cfg.MaxPageSize == 0 {
+		s.MaxPageSize = -1
+	}
+	if cfg.RateLimit > 0 {
+		s.RateLimiter = scraper.NewHostRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	}
+	if cfg.BreakerFailures > 0 {
+		s.Breaker = &scraper.CircuitBreaker{Threshold: cfg.BreakerFailures, Cooldown: cfg.BreakerCooldown}
+	}
+	if cfg.Adaptive {
+		s.Adaptive = &scraper.AdaptiveConcurrency{Start: cfg.AdaptiveStart, Min: cfg.AdaptiveMin}
+	}
+	if cfg.CookieJar {
+		s.Jar, _ = cookiejar.New(nil)
+	}
+	if cfg.Progress {
+		s.Progress = func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\rScraped %d of %d URLs", done, total)
+			if done == total {
+				fmt.Fprintln(os.Stderr)
+			}
+		}
+	}
+	if cfg.CachePath != "" {
+		cache, err := scraper.LoadResponseCache(cfg.CachePath)
+		if err != nil {
+			return fmt.Errorf("reading cache %s: %w", cfg.CachePath, err)
+		}
+		s.Cache = cache
+	}
+	if cfg.HARPath != "" {
+		s.HAR = &scraper.HARRecorder{}
+	}
+	scraper.DefaultScraper = s
+
+	// Parse the sitemap and get all the URLs
+	var err error
+	var sitemapEntries []scraper.SitemapEntry
+	var fileURLs []string
+	if cfg.SitemapURL != "" {
+		sitemapEntries, err = s.ParseSitemapEntries(cfg.SitemapURL)
+		if err != nil {
+			return fmt.Errorf("parsing sitemap %s: %w", cfg.SitemapURL, err)
+		}
+	}
+
+	// Parse the -sitemaps list, giving up only when none of them could be read
+	if len(cfg.Sitemaps) > 0 {
+		found, errs := s.MergeSitemaps(cfg.Sitemaps)
+		if len(errs) == len(cfg.Sitemaps) {
+			var all []error
+			for sitemap, err := range errs {
+				all = append(all, fmt.Errorf("%s: %w", sitemap, err))
+			}
+			return fmt.Errorf("parsing sitemaps: %w", errors.Join(all...))
+		}
+		if len(errs) > 0 {
+			logger.Warn("Some sitemaps could not be parsed", "failed", len(errs), "total", len(cfg.Sitemaps))
+		}
+		sitemapEntries = append(sitemapEntries, found...)
+	}
+
+	// Parse every sitemap the site's robots.txt points to
+	if cfg.Site != "" {
+		discovered, err := s.DiscoverSitemaps(cfg.Site)
+		if err != nil {
+			return fmt.Errorf("reading robots.txt of %s: %w", cfg.Site, err)
+		}
+		if len(discovered) == 0 {
+			logger.Warn("No sitemaps listed in robots.txt", "site", cfg.Site)
+		}
+		found, errs := s.MergeSitemaps(discovered)
+		if len(errs) > 0 {
+			logger.Warn("Some sitemaps listed in robots.txt could not be parsed", "site", cfg.Site, "failed", len(errs), "total", len(discovered))
+		}
+		sitemapEntries = append(sitemapEntries, found...)
+	}
+
+	// Sitemaps of one site often list the same pages
+	sitemapEntries = scraper.DedupeSitemapEntries(sitemapEntries, cfg.IgnoreTrailingSlash)
+
+	// Scrape the most important or most recently changed pages first
+	scraper.SortSitemapEntries(sitemapEntries, cfg.SitemapOrder)
+	sitemapURLs := scraper.SitemapLocs(sitemapEntries)
+
+	// Add any URLs listed in the URL file
+	if cfg.URLsFile != "" {
+		fileURLs, err = scraper.ReadURLsFile(cfg.URLsFile)
+		if err != nil {
+			return fmt.Errorf("reading URL file: %w", err)
+		}
+	}
+	urls := scraper.MergeURLs(sitemapURLs, fileURLs)
+	urls = scraper.FilterURLs(urls, cfg.Include, cfg.Exclude)
+	urls = scraper.LimitURLs(urls, cfg.Limit, cfg.Sample)
+
+	// A dry run only lists what would be scraped
+	if cfg.DryRun {
+		for _, url := range urls {
+			fmt.Println(url)
+		}
+		return nil
+	}
+
+	// Create output file, or write to stdout for "-". Progress messages then
+	// go to stderr so they don't mix with the results.
+	var output io.Writer = os.Stdout
+	status := os.Stdout
+	if cfg.OutputPath == "-" {
+		status = os.Stderr
+	} else {
+		outputFile, err := os.Create(cfg.OutputPath)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+	buffered := bufio.NewWriter(output)
+	writer := scraper.OutputFormats[cfg.Format].NewWriter(buffered, cfg.Fields)
+
+	// SIGINT, SIGTERM or running past -max-duration stops the scrape early
+	// and the pages scraped so far are still written out
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if cfg.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.MaxDuration)
+		defer cancel()
+	}
+
+	// The parser compares images by the -dedupe-key, which may need the
+	// scraper to fetch them until the scrape is stopped
+	dedupeKey := dedupeKeys[cfg.DedupeKey](ctx, s)
+	s.Parser = scraper.DefaultParser{
+		KeepDuplicates: cfg.Dedupe == "none",
+		PreferredTypes: cfg.PreferredTypes,
+		Normalizer:     cfg.Normalizer,
+		Video:          cfg.Video,
+		Audio:          cfg.Audio,
+		DedupeKey:      dedupeKey,
+	}
+
+	// Each page is filtered, deduplicated and written out as soon as it is
+	// scraped, keeping only what the summary and downloads need. When images
+	// are verified or probed the pages are held back until that is done.
+	var summary scraper.Summary
+	var imageURLs []string
+	var pending []scraper.MediaData
+	var writeErr error
+	emit := func(res scraper.MediaData) {
+		// Save the result to the file, giving up on the output after the
+		// first failure. Pages without images still count in the summary
+		// when they are left out.
+		if writeErr == nil && (!cfg.OnlyWithImages || len(res.ImageURLs) > 0) {
+			writeErr = writer.WriteResult(res)
+		}
+		summary.Add(res)
+		if cfg.DownloadDir != "" {
+			imageURLs = append(imageURLs, res.ImageURLs...)
+		}
+	}
+	pages, images, hashes := scraper.PageSet{}, scraper.ImageSet{Key: dedupeKey}, scraper.ImageSets{}
+	finals := scraper.FinalURLSet{}
+	handle := func(res scraper.MediaData) {
+		// Several listed URLs may redirect to the same page
+		if cfg.Dedupe != "none" && !finals.Add(res) {
+			logger.Info("Skipping page already reached through another URL", "url", res.RequestedURL, "final_url", res.FinalURL)
+			return
+		}
+
+		// Keep only the image types that were asked for
+		if cfg.Filter.Active() {
+			cfg.Filter.Apply(&res)
+		}
+
+		// Flag pages repeating an earlier page's images, before global
+		// dedupe empties their image lists
+		if cfg.DetectDuplicates {
+			hashes.Mark(&res)
+		}
+
+		// Drop pages that are another copy of an earlier page, then images
+		// already listed for an earlier page
+		if cfg.Dedupe == "global" {
+			if !pages.Add(res) {
+				logger.Info("Skipping duplicate page", "url", res.URL, "canonical_url", res.CanonicalURL)
+				return
+			}
+			images.Dedupe(&res)
+		}
+
+		if cfg.VerifyImages || cfg.ProbeImages {
+			pending = append(pending, res)
+			return
+		}
+		emit(res)
+	}
+
+	// With -sort the pages are held back and handled in URL order once they
+	// are all in, so that dedupe and duplicate detection don't depend on the
+	// order they finished in either
+	var unsorted []scraper.MediaData
+	collect := func(res scraper.MediaData) {
+		if cfg.Sort {
+			unsorted = append(unsorted, res)
+			return
+		}
+		handle(res)
+	}
+
+	// Scrape the URLs for images with concurrency, or crawl out from the
+	// seed when there is one
+	scrapeErrs := make(map[string]error)
+	stopped := 0
+	if cfg.CrawlSeed != "" {
+		var results []scraper.MediaData
+		results, scrapeErrs = s.CrawlContext(ctx, cfg.CrawlSeed, cfg.MaxDepth, !cfg.CrossHost)
+		for url, err := range scrapeErrs {
+			if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				delete(scrapeErrs, url)
+				stopped++
+			}
+		}
+		for _, res := range results {
+			collect(res)
+		}
+	} else {
+		// Pages and failures arrive on one channel, in the order they finish.
+		// URLs cut off by stopping early are skipped rather than failed, the
+		// same as those never started.
+		finished := 0
+		for res := range s.ScrapeResults(ctx, urls) {
+			if res.Err != nil && ctx.Err() != nil && errors.Is(res.Err, ctx.Err()) {
+				continue
+			}
+			finished++
+			if res.Err != nil {
+				scrapeErrs[res.URL] = res.Err
+				continue
+			}
+			collect(res.Data)
+		}
+		stopped = len(urls) - finished
+	}
+	// Timings vary from run to run, so sorted output leaves them zero
+	// unless -fields names them
+	scraper.SortResults(unsorted)
+	for _, res := range unsorted {
+		if !slices.Contains(cfg.Fields, "fetch_duration_ns") {
+			res.FetchDuration = 0
+		}
+		if !slices.Contains(cfg.Fields, "parse_duration_ns") {
+			res.ParseDuration = 0
+		}
+		handle(res)
+	}
+
+	// Check or probe every image once the pages are in, then write the
+	// pages out. An interrupted scrape is written out without the checks.
+	if cfg.VerifyImages || cfg.ProbeImages {
+		var all []string
+		for _, res := range pending {
+			all = append(all, res.ImageURLs...)
+		}
+		var statuses map[string]int
+		var infos map[string]scraper.ImageInfo
+		if cfg.VerifyImages && ctx.Err() == nil {
+			statuses = s.VerifyImages(ctx, all)
+		}
+		if cfg.ProbeImages && ctx.Err() == nil {
+			infos = s.ProbeImages(ctx, all)
+		}
+		complete := ctx.Err() == nil
+		for _, res := range pending {
+			if complete && cfg.VerifyImages {
+				scraper.SetImageStatuses(&res, statuses)
+			}
+			if complete && cfg.ProbeImages {
+				scraper.SetImageInfo(&res, infos)
+			}
+			emit(res)
+		}
+	}
+	interrupted := ctx.Err() != nil
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	// Restore the default handling so a second signal exits straight away
+	stop()
+	if timedOut {
+		logger.Warn("Reached -max-duration, finishing the output with the pages scraped so far", "max_duration", cfg.MaxDuration, "scraped", summary.Pages)
+	} else if interrupted {
+		logger.Warn("Interrupted, finishing the output with the pages scraped so far", "scraped", summary.Pages)
+	}
+	if s.Cache != nil {
+		if err := s.Cache.Save(); err != nil {
+			logger.Error("Error saving cache", "path", cfg.CachePath, "err", err)
+		}
+	}
+	if s.HAR != nil {
+		if err := s.HAR.WriteFile(cfg.HARPath); err != nil {
+			logger.Error("Error writing HAR file", "path", cfg.HARPath, "err", err)
+		}
+	}
+	summary.AddErrors(scrapeErrs)
+	summary.Skipped += stopped
+	if summary.Failed > 0 {
+		logger.Warn("Some URLs could not be scraped", "failed", summary.Failed, "total", summary.Pages+len(scrapeErrs))
+	}
+
+	// Finish the output and flush what is still buffered
+	if writeErr == nil {
+		writeErr = writer.Close()
+	}
+	if err := buffered.Flush(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		return fmt.Errorf("writing results to %s: %w", cfg.OutputPath, writeErr)
+	}
+
+	fmt.Fprintf(status, "Image extraction completed. Results saved to %s\n", cfg.OutputPath)
+	fmt.Fprintln(status, summary)
+
+	// Fetch the image files themselves when asked to, unless the run was
+	// interrupted
+	if cfg.DownloadDir != "" && !interrupted {
+		if err := scraper.DownloadImages(imageURLs, cfg.DownloadDir, cfg.Concurrency); err != nil {
+			logger.Error("Some images failed to download", "err", err)
+		}
+		fmt.Fprintf(status, "Images downloaded to %s\n", cfg.DownloadDir)
+	}
+	return nil
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/shanmukasadhu/GOImageScrape/scraper"
+)
+
+// testSite starts a server with a sitemap at /sitemap.xml listing pages
+// /page/0 to /page/n-1, each with one image, and returns it with a function
+// reporting the paths requested so far
+func testSite(t *testing.T, n int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/sitemap.xml":
+			fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+			fmt.Fprintln(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+			for i := 0; i < n; i++ {
+				fmt.Fprintf(w, "<url><loc>http://%s/page/%d</loc></url>\n", r.Host, i)
+			}
+			fmt.Fprintln(w, "</urlset>")
+		case strings.HasPrefix(r.URL.Path, "/page/"):
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><head><title>Page %s</title></head><body><img src="%s.png"></body></html>`, r.URL.Path, r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, requested...)
+	}
+}
+
+// captureStdout runs f and returns what it printed to standard output
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stdout := os.Stdout
+	os.Stdout = file
+	defer func() { os.Stdout = stdout }()
+
+	f()
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// testConfig parses args as the command line, quietening the logs
+func testConfig(t *testing.T, args ...string) Config {
+	t.Helper()
+	cfg, err := parseFlags(append([]string{"-log-level", "error"}, args...))
+	if err != nil {
+		t.Fatalf("parseFlags(%q): %v", args, err)
+	}
+	return cfg
+}
+
+func TestRunDryRun(t *testing.T) {
+	server, requested := testSite(t, 3)
+	outPath := filepath.Join(t.TempDir(), "results.txt")
+
+	cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", outPath, "-dry-run", "-limit", "2")
+
+	var err error
+	out := captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	want := server.URL + "/page/0\n" + server.URL + "/page/1\n"
+	if out != want {
+		t.Errorf("dry run printed %q, want %q", out, want)
+	}
+	// Only the sitemap is fetched, and nothing is written
+	if got := requested(); len(got) != 1 || got[0] != "/sitemap.xml" {
+		t.Errorf("dry run requested %q, want only the sitemap", got)
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("dry run created the output file (stat error %v)", err)
+	}
+}
+
+func TestRunInterruptedWritesResults(t *testing.T) {
+	// Pages after the second hang until their request is aborted
+	var mu sync.Mutex
+	served := 0
+	hanging := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sitemap.xml" {
+			var locs []string
+			for i := 0; i < 10; i++ {
+				locs = append(locs, fmt.Sprintf("<url><loc>http://%s/page/%d</loc></url>", r.Host, i))
+			}
+			fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">%s</urlset>`, strings.Join(locs, ""))
+			return
+		}
+		mu.Lock()
+		served++
+		hang := served > 2
+		if served == 3 {
+			close(hanging)
+		}
+		mu.Unlock()
+		if hang {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="%s.png"></body></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	outPath := filepath.Join(t.TempDir(), "results.json")
+	cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", outPath, "-format", "json",
+		"-concurrency", "1", "-robots=false")
+
+	// Interrupt this process once the third page is hanging; run catches
+	// the signal itself
+	go func() {
+		<-hanging
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+	}()
+	var err error
+	captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("interrupted run: %v", err)
+	}
+
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []scraper.MediaData
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("output isn't complete JSON: %v\n%s", err, out)
+	}
+	if len(results) != 2 {
+		t.Errorf("output has %d pages, want the 2 scraped before stopping", len(results))
+	}
+}
+
+func TestRunStoppedEarlyWritesResults(t *testing.T) {
+	// Pages after the second hang until their request is aborted
+	var mu sync.Mutex
+	served := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sitemap.xml" {
+			var locs []string
+			for i := 0; i < 10; i++ {
+				locs = append(locs, fmt.Sprintf("<url><loc>%s/page/%d</loc></url>", server.URL, i))
+			}
+			fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">%s</urlset>`, strings.Join(locs, ""))
+			return
+		}
+		mu.Lock()
+		served++
+		hang := served > 2
+		mu.Unlock()
+		if hang {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="%s.png"></body></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	// Running out of -max-duration cancels the scrape the same way a signal
+	// does
+	outPath := filepath.Join(t.TempDir(), "results.json")
+	cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", outPath, "-format", "json",
+		"-concurrency", "1", "-max-duration", "300ms", "-robots=false")
+	var err error
+	captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []scraper.MediaData
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("output isn't complete JSON: %v\n%s", err, out)
+	}
+	if len(results) != 2 {
+		t.Errorf("output has %d pages, want the 2 scraped before stopping", len(results))
+	}
+}
+
+func TestRunOutputToStdout(t *testing.T) {
+	server, _ := testSite(t, 2)
+
+	// With -out - only the results go to standard output
+	cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", "-", "-format", "json", "-robots=false")
+	var err error
+	out := captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var results []scraper.MediaData
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("stdout isn't just the JSON results: %v\n%s", err, out)
+	}
+	if len(results) != 2 {
+		t.Errorf("got %d results, want 2", len(results))
+	}
+}
+
+func TestRunSiteWarnsOnBadSitemaps(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "Sitemap: %s/sitemap.xml\nSitemap: %s/missing.xml\n", server.URL, server.URL)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/page</loc></url></urlset>`, server.URL)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><img src="/a.png"></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	logOutput = &logs
+	defer func() { logOutput = os.Stderr }()
+	cfg := testConfig(t, "-site", server.URL, "-out", filepath.Join(t.TempDir(), "results.txt"), "-log-level", "warn")
+	var err error
+	out := captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Some sitemaps listed in robots.txt could not be parsed") {
+		t.Errorf("no warning about the missing sitemap in:\n%s", logs.String())
+	}
+	if !strings.Contains(out, "Pages: 1,") {
+		t.Errorf("the sitemap that could be read wasn't scraped:\n%s", out)
+	}
+}
+
+func TestRunWritesEveryPage(t *testing.T) {
+	const pages = 30
+	server, _ := testSite(t, pages)
+	outPath := filepath.Join(t.TempDir(), "results.json")
+	cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", outPath, "-format", "json", "-concurrency", "8", "-robots=false")
+
+	var err error
+	status := captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(status, "Results saved to "+outPath) {
+		t.Errorf("run printed %q, want the output path", status)
+	}
+
+	// Each page is written as it finishes, so the order varies, but every
+	// page must be there once with its image
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []scraper.MediaData
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("output isn't a JSON array: %v\n%s", err, out)
+	}
+	seen := make(map[string]bool)
+	for _, res := range results {
+		if seen[res.URL] {
+			t.Errorf("%s was written twice", res.URL)
+		}
+		seen[res.URL] = true
+		if want := res.URL + ".png"; len(res.ImageURLs) != 1 || res.ImageURLs[0] != want {
+			t.Errorf("%s has images %q, want %q", res.URL, res.ImageURLs, want)
+		}
+	}
+	for i := 0; i < pages; i++ {
+		if page := fmt.Sprintf("%s/page/%d", server.URL, i); !seen[page] {
+			t.Errorf("%s is missing from the output", page)
+		}
+	}
+}
+
+func TestRunOnlyWithImages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/with</loc></url><url><loc>%s/without</loc></url></urlset>`, server.URL, server.URL)
+		case "/with":
+			fmt.Fprint(w, `<html><head><title>With</title></head><body><img src="/a.png"></body></html>`)
+		case "/without":
+			fmt.Fprint(w, `<html><head><title>Without</title></head><body></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outPath := filepath.Join(t.TempDir(), "results.ndjson")
+	cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", outPath, "-format", "ndjson",
+		"-fields", "url,image_urls", "-only-with-images")
+	var err error
+	captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`{"url":"%s/with","image_urls":["%s/a.png"]}`+"\n", server.URL, server.URL)
+	if string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestRunDedupesRedirects(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/a</loc></url><url><loc>%s/b</loc></url></urlset>`, server.URL, server.URL)
+		case "/a", "/b":
+			http.Redirect(w, r, "/target", http.StatusFound)
+		case "/target":
+			fmt.Fprint(w, `<html><body><img src="/target.png"></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	outPath := filepath.Join(t.TempDir(), "results.json")
+	cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", outPath, "-format", "json")
+	var err error
+	captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []scraper.MediaData
+	if err := json.Unmarshal(out, &results); err != nil {
+		t.Fatalf("output isn't a JSON array: %v\n%s", err, out)
+	}
+	if len(results) != 1 || results[0].FinalURL != server.URL+"/target" {
+		t.Errorf("output = %+v, want the one page both URLs redirect to", results)
+	}
+}
+
+func TestRunCrawlStoppedEarlyIsNotTimeout(t *testing.T) {
+	// The seed links to pages that hang until their request is aborted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><a href="/a">a</a><a href="/b">b</a></body></html>`)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	outPath := filepath.Join(t.TempDir(), "results.txt")
+	cfg := testConfig(t, "-crawl", server.URL+"/", "-out", outPath, "-max-duration", "300ms", "-robots=false")
+	var err error
+	out := captureStdout(t, func() { err = run(cfg) })
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(out, "failed URLs: 0") || strings.Contains(out, "timeout") {
+		t.Errorf("pages cut off by -max-duration were counted as failed:\n%s", out)
+	}
+	if !strings.Contains(out, "skipped URLs: 2") {
+		t.Errorf("summary doesn't count the 2 pages cut off as skipped:\n%s", out)
+	}
+}
+
+func TestRunWarnsOnHighConcurrency(t *testing.T) {
+	if scraper.DefaultConcurrency > politeConcurrency {
+		t.Errorf("DefaultConcurrency %d is above the polite limit %d", scraper.DefaultConcurrency, politeConcurrency)
+	}
+
+	server, _ := testSite(t, 2)
+	tests := []struct {
+		args []string
+		warn bool
+	}{
+		{[]string{"-concurrency", "50"}, true},
+		{[]string{"-concurrency", "50", "-rate", "100"}, false},
+		{[]string{"-concurrency", "50", "-delay", "1ms"}, false},
+		{[]string{"-concurrency", fmt.Sprint(politeConcurrency)}, false},
+	}
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		logOutput = &logs
+		args := append([]string{"-sitemap", server.URL + "/sitemap.xml", "-out", filepath.Join(t.TempDir(), "results.txt"), "-log-level", "warn"}, tt.args...)
+		cfg := testConfig(t, args...)
+		var err error
+		captureStdout(t, func() { err = run(cfg) })
+		logOutput = os.Stderr
+		if err != nil {
+			t.Fatalf("%q: run: %v", tt.args, err)
+		}
+		if warned := strings.Contains(logs.String(), "High -concurrency"); warned != tt.warn {
+			t.Errorf("%q: warned = %v, want %v; logs:\n%s", tt.args, warned, tt.warn, logs.String())
+		}
+	}
+}
+
+func TestRunSortedOutputIsDeterministic(t *testing.T) {
+	server, _ := testSite(t, 20)
+	for _, format := range []string{"json", "text", "csv"} {
+		var outputs []string
+		for i := 0; i < 2; i++ {
+			outPath := filepath.Join(t.TempDir(), "results")
+			cfg := testConfig(t, "-sitemap", server.URL+"/sitemap.xml", "-out", outPath, "-format", format, "-concurrency", "8", "-sort")
+			var err error
+			captureStdout(t, func() { err = run(cfg) })
+			if err != nil {
+				t.Fatalf("%s: run: %v", format, err)
+			}
+			out, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs = append(outputs, string(out))
+		}
+		if outputs[0] != outputs[1] {
+			t.Errorf("%s: two sorted runs differ:\n%s\n---\n%s", format, outputs[0], outputs[1])
+		}
+		// Pages come in URL order, so /page/10 before /page/2
+		if first, second := strings.Index(outputs[0], "/page/10"), strings.Index(outputs[0], "/page/2"); first < 0 || first > second {
+			t.Errorf("%s: output isn't sorted by URL:\n%s", format, outputs[0])
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// AdaptiveConcurrency bounds a scrape whose concurrency follows the error
+// rate: it grows by one each time a full round of requests succeeds and
+// halves when the site starts answering with 429s, 5xxs or failed requests.
+type AdaptiveConcurrency struct {
+	// Start is the concurrency to begin with; zero means Min
+	Start int
+	// Min is the lowest it backs off to; zero means 1
+	Min int
+	// Max is the highest it grows to; zero means the scraper's Concurrency
+	Max int
+}
+
+// aimdLimiter caps the number of requests in flight, raising the cap
+// additively while requests succeed and cutting it multiplicatively when
+// they fail
+type aimdLimiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	lo, hi int
+	active int
+	// successes counts the requests that succeeded since the limit last
+	// changed, and completed counts every request finished since it was
+	// last cut
+	successes int
+	completed int
+}
+
+// newAIMDLimiter returns a limiter allowing start requests at once, kept
+// between lo and hi
+func newAIMDLimiter(start, lo, hi int) *aimdLimiter {
+	lo = max(lo, 1)
+	hi = max(hi, lo)
+	start = min(max(start, lo), hi)
+	// The first failure may cut the limit straight away
+	l := &aimdLimiter{limit: start, lo: lo, hi: hi, completed: start}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until fewer than limit requests are in flight and counts
+// the caller's request as one of them
+func (l *aimdLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// release ends a request that finished with err and adjusts the limit. A
+// failure only cuts the limit once a full round of requests has finished
+// since the last cut, so a burst of failures from requests that were
+// already in flight counts once.
+func (l *aimdLimiter) release(err error) (limit int, changed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.completed++
+	previous := l.limit
+
+	if overloaded(err) {
+		l.successes = 0
+		if l.completed >= l.limit {
+			l.limit = max(l.limit/2, l.lo)
+			l.completed = 0
+		}
+	} else {
+		l.successes++
+		if l.successes >= l.limit && l.limit < l.hi {
+			l.limit++
+			l.successes = 0
+		}
+	}
+
+	l.cond.Broadcast()
+	return l.limit, l.limit != previous
+}
+
+// overloaded reports whether err suggests the site is struggling to keep up:
+// a 429 or 5xx response, or a request that failed outright. Pages that are
+// simply missing, disallowed or skipped by the circuit breaker don't count.
+func overloaded(err error) bool {
+	if err == nil || errors.Is(err, errRobotsDisallowed) || errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, context.Canceled) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500
+	}
+	return true
+}
+
+// adaptiveLimiter returns the limiter shared by every scrape the scraper
+// runs, or nil when Adaptive isn't set
+func (s *Scraper) adaptiveLimiter(concurrency int) *aimdLimiter {
+	if s.Adaptive == nil {
+		return nil
+	}
+	s.limiterOnce.Do(func() {
+		hi := s.Adaptive.Max
+		if hi <= 0 {
+			hi = concurrency
+		}
+		s.limiter = newAIMDLimiter(s.Adaptive.Start, s.Adaptive.Min, s.boundConcurrency(hi))
+	})
+	return s.limiter
+}
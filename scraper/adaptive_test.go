@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAIMDLimiter(t *testing.T) {
+	l := newAIMDLimiter(4, 1, 8)
+	tooMany := &StatusError{StatusCode: 429}
+	steps := []struct {
+		err  error
+		want int
+	}{
+		// A full round of successes adds one
+		{nil, 4}, {nil, 4}, {nil, 4}, {nil, 5},
+		// As 429s start coming back the limit halves, once per round
+		{tooMany, 2},
+		{tooMany, 2},
+		{tooMany, 1},
+		{tooMany, 1},
+		{&StatusError{StatusCode: 503}, 1},
+		// and climbs back slowly once they stop
+		{nil, 2}, {nil, 2}, {nil, 3},
+	}
+	for i, step := range steps {
+		l.acquire()
+		if limit, _ := l.release(step.err); limit != step.want {
+			t.Fatalf("step %d (%v): limit = %d, want %d", i, step.err, limit, step.want)
+		}
+	}
+
+	// It never grows past the maximum
+	l = newAIMDLimiter(2, 1, 2)
+	for i := 0; i < 10; i++ {
+		l.acquire()
+		if limit, _ := l.release(nil); limit != 2 {
+			t.Fatalf("limit = %d, want the maximum 2", limit)
+		}
+	}
+}
+
+func TestAIMDLimiterBlocks(t *testing.T) {
+	l := newAIMDLimiter(1, 1, 1)
+	l.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		l.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("a second request started while the limit was 1")
+	case <-time.After(20 * time.Millisecond):
+	}
+	l.release(nil)
+	<-acquired
+}
+
+func TestOverloaded(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&StatusError{StatusCode: 429}, true},
+		{&StatusError{StatusCode: 502}, true},
+		{&StatusError{StatusCode: 404}, false},
+		{errRobotsDisallowed, false},
+		{ErrCircuitOpen, false},
+		{fmt.Errorf("fetching: %w", context.Canceled), false},
+		{errors.New("connection reset"), true},
+	}
+	for _, tt := range tests {
+		if got := overloaded(tt.err); got != tt.want {
+			t.Errorf("overloaded(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAdaptiveLimiterBounds(t *testing.T) {
+	if l := (&Scraper{}).adaptiveLimiter(10); l != nil {
+		t.Error("adaptiveLimiter without Adaptive isn't nil")
+	}
+	s := &Scraper{Adaptive: &AdaptiveConcurrency{}}
+	l := s.adaptiveLimiter(10)
+	if l.limit != 1 || l.lo != 1 || l.hi != 10 {
+		t.Errorf("zero bounds give limit %d in [%d, %d], want 1 in [1, 10]", l.limit, l.lo, l.hi)
+	}
+	if s.adaptiveLimiter(20) != l {
+		t.Error("a second scrape got a new limiter")
+	}
+}
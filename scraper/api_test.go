@@ -0,0 +1,81 @@
+package scraper_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shanmukasadhu/GOImageScrape/scraper"
+)
+
+func TestMetaDescription(t *testing.T) {
+	page := `<html><head>
+<meta name="description" content="Photos from the trip">
+</head><body></body></html>`
+	data, err := scraper.DefaultParser{}.GetMediaDataFromReader(strings.NewReader(page), "https://example.com/")
+	if err != nil {
+		t.Fatalf("GetMediaDataFromReader: %v", err)
+	}
+	if data.MetaDescription != "Photos from the trip" {
+		t.Errorf("MetaDescription = %q, want %q", data.MetaDescription, "Photos from the trip")
+	}
+}
+
+// sizeParser is a Parser written outside the package
+type sizeParser struct{}
+
+func (sizeParser) GetMediaData(resp *http.Response) (scraper.MediaData, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return scraper.MediaData{}, err
+	}
+	return scraper.MediaData{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode, Title: fmt.Sprint(len(body))}, nil
+}
+
+func TestPublicAPI(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>%s/a</loc></url><url><loc>%s/b</loc></url></urlset>`, server.URL, server.URL)
+	})
+	for _, name := range []string{"a", "b"} {
+		mux.HandleFunc("/"+name, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><body><img src="/%s.png"></body></html>`, name)
+		})
+	}
+
+	s := &scraper.Scraper{Concurrency: 2}
+	results, errs, err := s.ScrapeSitemap(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ScrapeSitemap: %v", err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("ScrapeSitemap errors = %v, want none", errs)
+	}
+	scraper.SortResults(results)
+	if len(results) != 2 || results[0].URL != server.URL+"/a" || !slices.Equal(results[1].ImageURLs, []string{server.URL + "/b.png"}) {
+		t.Fatalf("ScrapeSitemap results = %+v", results)
+	}
+
+	var out bytes.Buffer
+	if err := scraper.OutputFormats["json"].NewWriter(&out, nil).Write(results); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	var decoded []scraper.MediaData
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 {
+		t.Errorf("JSON output %s decodes to %d results, %v", out.String(), len(decoded), err)
+	}
+
+	results, errs = scraper.ScrapeImages([]string{server.URL + "/a"}, sizeParser{}, 1)
+	if len(errs) != 0 || len(results) != 1 || results[0].Title == "" || results[0].Title == "0" {
+		t.Errorf("ScrapeImages with a custom parser = %+v, %v", results, errs)
+	}
+}
@@ -0,0 +1,83 @@
+package scraper
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for requests skipped because their host's
+// circuit breaker is open
+var ErrCircuitOpen = errors.New("host circuit breaker open")
+
+// CircuitBreaker stops sending requests to a host that keeps failing. After
+// Threshold failed attempts in a row, retries included, requests to the host
+// fail straight away with ErrCircuitOpen until Cooldown has passed. The next
+// failure after that opens it again; a success closes it.
+type CircuitBreaker struct {
+	// Threshold is how many failures in a row open the breaker; zero or
+	// less disables it
+	Threshold int
+	// Cooldown is how long requests are skipped once the breaker opens
+	Cooldown time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostCircuit
+}
+
+// hostCircuit tracks the recent failures of a single host
+type hostCircuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+// breakerHost returns the host rawURL counts against
+func breakerHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// allow reports whether a request to host may be sent
+func (b *CircuitBreaker) allow(host string) bool {
+	if b == nil || b.Threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit, ok := b.hosts[host]
+	return !ok || !time.Now().Before(circuit.openUntil)
+}
+
+// record counts the outcome of a request to host and reports whether it
+// opened the breaker
+func (b *CircuitBreaker) record(host string, failed bool) bool {
+	if b == nil || b.Threshold <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		delete(b.hosts, host)
+		return false
+	}
+	if b.hosts == nil {
+		b.hosts = make(map[string]*hostCircuit)
+	}
+	circuit, ok := b.hosts[host]
+	if !ok {
+		circuit = &hostCircuit{}
+		b.hosts[host] = circuit
+	}
+	circuit.failures++
+	if circuit.failures < b.Threshold {
+		return false
+	}
+	circuit.openUntil = time.Now().Add(b.Cooldown)
+	return true
+}
@@ -0,0 +1,85 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerScrape(t *testing.T) {
+	var failing atomic.Int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+	good := pageServer(t, nil)
+	// The servers only count as different hosts under different names
+	goodURL := strings.Replace(good.URL, "127.0.0.1", "localhost", 1)
+
+	var urls []string
+	for i := 0; i < 5; i++ {
+		urls = append(urls, fmt.Sprintf("%s/page/%d", bad.URL, i), fmt.Sprintf("%s/page/%d", goodURL, i))
+	}
+	s := &Scraper{Concurrency: 1, IgnoreRobots: true, Breaker: &CircuitBreaker{Threshold: 3, Cooldown: time.Hour}}
+	results, errs := s.Scrape(urls)
+
+	if len(results) != 5 {
+		t.Errorf("Scrape gave %d pages, want the 5 from the healthy host", len(results))
+	}
+	if got := failing.Load(); got != 3 {
+		t.Errorf("failing host got %d requests, want the threshold of 3", got)
+	}
+	skipped := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrCircuitOpen) {
+			skipped++
+			if Categorize(err) != CategorySkipped {
+				t.Errorf("Categorize(%v) = %s, want %s", err, Categorize(err), CategorySkipped)
+			}
+		}
+	}
+	if len(errs) != 5 || skipped != 4 {
+		t.Errorf("Scrape errors = %v, want 5 with 4 skipped by the breaker", errs)
+	}
+}
+
+func TestCircuitBreakerCooldown(t *testing.T) {
+	b := &CircuitBreaker{Threshold: 2, Cooldown: 20 * time.Millisecond}
+	const host = "example.com"
+
+	if b.record(host, true) || !b.allow(host) {
+		t.Fatal("one failure opened the breaker")
+	}
+	if !b.record(host, true) || b.allow(host) {
+		t.Fatal("the threshold of failures didn't open the breaker")
+	}
+	if !b.allow("other.example.com") {
+		t.Error("another host's requests were stopped")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow(host) {
+		t.Fatal("the breaker is still open after the cooldown")
+	}
+	// One more failure opens it again straight away
+	if !b.record(host, true) || b.allow(host) {
+		t.Fatal("a failure after the cooldown didn't reopen the breaker")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	b.record(host, false)
+	if b.record(host, true) || !b.allow(host) {
+		t.Error("a success didn't reset the failure count")
+	}
+
+	var disabled *CircuitBreaker
+	if disabled.record(host, true) || !disabled.allow(host) {
+		t.Error("a nil breaker stopped requests")
+	}
+}
@@ -0,0 +1,106 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheEntry is what the cache remembers about one page
+type cacheEntry struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Data         MediaData `json:"data"`
+}
+
+// ResponseCache keeps the validators and media data of pages already scraped
+// so that a later run can fetch them conditionally and reuse the data when
+// the server answers 304 Not Modified. It is safe for concurrent use.
+type ResponseCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// LoadResponseCache reads the cache stored at path. A missing file gives an
+// empty cache that will be created by Save.
+func LoadResponseCache(path string) (*ResponseCache, error) {
+	cache := &ResponseCache{path: path, entries: make(map[string]cacheEntry)}
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &cache.entries); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Save writes the cache back to its file. The file is replaced in one step
+// so an interrupted save doesn't leave it truncated.
+func (c *ResponseCache) Save() error {
+	c.mu.Lock()
+	contents, err := json.Marshal(c.entries)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), c.path)
+}
+
+// get returns the entry stored for url
+func (c *ResponseCache) get(url string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[url]
+	return entry, ok
+}
+
+// store remembers the media data scraped from resp, when the server gave a
+// validator to check it against next time
+func (c *ResponseCache) store(url string, resp *http.Response, data MediaData) {
+	entry := cacheEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Data:         data,
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = entry
+}
+
+// conditionalHeaders asks the server to answer 304 if the page hasn't
+// changed since the entry was stored
+func (e cacheEntry) conditionalHeaders() http.Header {
+	header := make(http.Header)
+	if e.ETag != "" {
+		header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		header.Set("If-Modified-Since", e.LastModified)
+	}
+	return header
+}
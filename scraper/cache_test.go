@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestResponseCache(t *testing.T) {
+	var mu sync.Mutex
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditional = append(conditional, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		mu.Unlock()
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 05 Oct 2026 10:00:00 GMT")
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Cached</title></head><body><img src="/a.jpg"></body></html>`)
+	}))
+	defer server.Close()
+	page := server.URL + "/page"
+	path := filepath.Join(t.TempDir(), "cache.json")
+
+	// The first run fetches the page and saves its validators
+	cache, err := LoadResponseCache(path)
+	if err != nil {
+		t.Fatalf("LoadResponseCache of a missing file: %v", err)
+	}
+	first, errs := (&Scraper{Cache: cache, IgnoreRobots: true}).Scrape([]string{page})
+	if len(first) != 1 || first[0].Unchanged {
+		t.Fatalf("first run gave %+v and errors %v, want the fetched page", first, errs)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// The next run asks whether it changed and reuses the data on a 304
+	cache, err = LoadResponseCache(path)
+	if err != nil {
+		t.Fatalf("LoadResponseCache: %v", err)
+	}
+	second, errs := (&Scraper{Cache: cache, IgnoreRobots: true}).Scrape([]string{page})
+	if len(second) != 1 {
+		t.Fatalf("second run errors = %v", errs)
+	}
+	res := second[0]
+	if !res.Unchanged || res.Title != "Cached" || !slices.Equal(res.ImageURLs, first[0].ImageURLs) {
+		t.Errorf("second run gave %+v, want the cached data marked unchanged", res)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"|", `"v1"|Mon, 05 Oct 2026 10:00:00 GMT`}
+	if !slices.Equal(conditional, want) {
+		t.Errorf("conditional headers sent = %q, want %q", conditional, want)
+	}
+}
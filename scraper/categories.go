@@ -0,0 +1,74 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ErrorCategory is the machine-readable cause of a failed URL, for
+// aggregating failures
+type ErrorCategory string
+
+// The categories Categorize sorts errors into
+const (
+	CategoryDNS               ErrorCategory = "dns"
+	CategoryTimeout           ErrorCategory = "timeout"
+	CategoryConnectionRefused ErrorCategory = "connection_refused"
+	CategoryHTTP4xx           ErrorCategory = "http_4xx"
+	CategoryHTTP5xx           ErrorCategory = "http_5xx"
+	CategoryParseError        ErrorCategory = "parse_error"
+	CategorySkipped           ErrorCategory = "skipped"
+	CategoryOther             ErrorCategory = "other"
+)
+
+// ParseError is returned for a page that was fetched but couldn't be parsed
+type ParseError struct {
+	Err error
+}
+
+// Error implements error
+func (e *ParseError) Error() string {
+	return "parsing page: " + e.Err.Error()
+}
+
+// Unwrap returns the parser's error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Categorize returns the category of an error from a scrape. URLs left out
+// on purpose, by robots.txt, the circuit breaker or cancellation, are
+// CategorySkipped.
+func Categorize(err error) ErrorCategory {
+	if errors.Is(err, errRobotsDisallowed) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) {
+		return CategorySkipped
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		switch {
+		case status.StatusCode >= 500:
+			return CategoryHTTP5xx
+		case status.StatusCode >= 400:
+			return CategoryHTTP4xx
+		}
+		return CategoryOther
+	}
+	var parse *ParseError
+	if errors.As(err, &parse) {
+		return CategoryParseError
+	}
+	var dns *net.DNSError
+	if errors.As(err, &dns) && !dns.IsTimeout {
+		return CategoryDNS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return CategoryConnectionRefused
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return CategoryTimeout
+	}
+	return CategoryOther
+}
@@ -0,0 +1,87 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCategorizeScrapeErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		case "/broken":
+			http.Error(w, "oops", http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	slow, missing, broken := server.URL+"/slow", server.URL+"/missing", server.URL+"/broken"
+	refused := "http://127.0.0.1:1/page"
+	s := &Scraper{Timeout: 50 * time.Millisecond, IgnoreRobots: true}
+	_, errs := s.Scrape([]string{slow, missing, broken, refused})
+
+	want := map[string]ErrorCategory{
+		slow:    CategoryTimeout,
+		missing: CategoryHTTP4xx,
+		broken:  CategoryHTTP5xx,
+		refused: CategoryConnectionRefused,
+	}
+	for url, category := range want {
+		if got := Categorize(errs[url]); got != category {
+			t.Errorf("%s: Categorize(%v) = %s, want %s", url, errs[url], got, category)
+		}
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCategory
+	}{
+		{&net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}, CategoryDNS},
+		{&net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}, CategoryTimeout},
+		{fmt.Errorf("fetching: %w", context.DeadlineExceeded), CategoryTimeout},
+		{&StatusError{StatusCode: 404}, CategoryHTTP4xx},
+		{&StatusError{StatusCode: 503}, CategoryHTTP5xx},
+		{&StatusError{StatusCode: 304}, CategoryOther},
+		{&ParseError{Err: errors.New("bad gzip")}, CategoryParseError},
+		{errRobotsDisallowed, CategorySkipped},
+		{fmt.Errorf("example.com: %w", ErrCircuitOpen), CategorySkipped},
+		{context.Canceled, CategorySkipped},
+		{errors.New("something else"), CategoryOther},
+	}
+	for _, tt := range tests {
+		if got := Categorize(tt.err); got != tt.want {
+			t.Errorf("Categorize(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestErrorLogCategory(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	var logs bytes.Buffer
+	s := &Scraper{IgnoreRobots: true, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	s.Scrape([]string{server.URL + "/missing", "http://127.0.0.1:1/page"})
+	for _, want := range []string{"category=http_4xx", "category=connection_refused"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("error logs missing %s in:\n%s", want, logs.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Crawl starts at seed and follows page links breadth-first, scraping every
+// page up to maxDepth links away from the seed (0 scrapes only the seed).
+// Each page is visited once. With sameHostOnly, links leaving the seed's host
+// are not followed. Pages are fetched with the scraper's concurrency, robots
+// and rate limiting like Scrape.
+//
+// A crawl has to extract links, so it always parses pages with a LinkParser.
+// It takes the options of the scraper's Parser when that is a DefaultParser
+// or LinkParser; any other Parser is not used, and a plain DefaultParser
+// takes its place.
+func (s *Scraper) Crawl(seed string, maxDepth int, sameHostOnly bool) ([]MediaData, map[string]error) {
+	return s.CrawlContext(context.Background(), seed, maxDepth, sameHostOnly)
+}
+
+// CrawlContext is like Crawl but stops when ctx is cancelled
+func (s *Scraper) CrawlContext(ctx context.Context, seed string, maxDepth int, sameHostOnly bool) ([]MediaData, map[string]error) {
+	// Links are filtered here against the seed's host rather than per page,
+	// so extract them all
+	var base DefaultParser
+	switch p := s.Parser.(type) {
+	case nil:
+	case DefaultParser:
+		base = p
+	case LinkParser:
+		base = p.DefaultParser
+	default:
+		s.log().Warn("Crawling with DefaultParser in place of the custom parser", "parser", fmt.Sprintf("%T", p))
+	}
+	if base.Logger == nil {
+		base.Logger = s.Logger
+	}
+	parser := LinkParser{DefaultParser: base, AllHosts: true}
+	concurrency := s.boundConcurrency(s.Concurrency)
+
+	seedHost := ""
+	if parsed, err := url.Parse(seed); err == nil {
+		seedHost = parsed.Hostname()
+	}
+
+	results := []MediaData{}
+	errs := make(map[string]error)
+	visited := map[string]bool{seed: true}
+	frontier := []string{seed}
+
+	for depth := 0; len(frontier) > 0 && ctx.Err() == nil; depth++ {
+		pages, pageErrs := s.scrape(ctx, frontier, parser, concurrency)
+		for url, err := range pageErrs {
+			errs[url] = err
+		}
+
+		var next []string
+		for _, page := range pages {
+			// A redirect may land on a page queued under another URL
+			visited[page.FinalURL] = true
+			results = append(results, page)
+			if depth >= maxDepth {
+				continue
+			}
+
+			for _, link := range page.Links {
+				if visited[link] {
+					continue
+				}
+				if sameHostOnly && !sameHost(link, seedHost) {
+					continue
+				}
+				visited[link] = true
+				next = append(next, link)
+			}
+		}
+		frontier = next
+	}
+	return results, errs
+}
+
+// sameHost reports whether rawURL is served by host
+func sameHost(rawURL, host string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && strings.EqualFold(parsed.Hostname(), host)
+}
@@ -0,0 +1,110 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// linkServer serves pages linking to the paths given for them in links, and
+// counts the requests for each path
+func linkServer(t *testing.T, links map[string][]string) (*httptest.Server, map[string]int, *sync.Mutex) {
+	t.Helper()
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		targets, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="/img%s.png">`, strings.TrimSuffix(r.URL.Path, "/"))
+		for _, target := range targets {
+			fmt.Fprintf(w, `<a href="%s">link</a>`, target)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	t.Cleanup(server.Close)
+	return server, hits, &mu
+}
+
+// resultPaths returns the sorted paths of the results' URLs under prefix
+func resultPaths(results []MediaData, prefix string) []string {
+	var paths []string
+	for _, res := range results {
+		paths = append(paths, strings.TrimPrefix(res.URL, prefix))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestCrawl(t *testing.T) {
+	other, otherHits, otherMu := linkServer(t, map[string][]string{"/elsewhere": nil})
+	// Both servers listen on 127.0.0.1, so reach the other by another name
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	server, hits, mu := linkServer(t, map[string][]string{
+		"/":  {"/a", "/b", "/a#again"},
+		"/a": {"/", "/c"},
+		"/b": {"/a", otherURL + "/elsewhere"},
+		"/c": {"/d"},
+		"/d": nil,
+	})
+
+	s := &Scraper{Concurrency: 3, IgnoreRobots: true}
+	results, errs := s.Crawl(server.URL+"/", 2, true)
+	if len(errs) != 0 {
+		t.Errorf("Crawl errors = %v", errs)
+	}
+	// /d is three links from the seed
+	if got, want := resultPaths(results, server.URL), []string{"/", "/a", "/b", "/c"}; !slices.Equal(got, want) {
+		t.Errorf("crawled %q, want %q", got, want)
+	}
+	mu.Lock()
+	for path, n := range hits {
+		if n != 1 {
+			t.Errorf("%s was fetched %d times, want once", path, n)
+		}
+	}
+	mu.Unlock()
+	otherMu.Lock()
+	if len(otherHits) != 0 {
+		t.Errorf("same-host crawl fetched %v from another host", otherHits)
+	}
+	otherMu.Unlock()
+
+	results, _ = (&Scraper{IgnoreRobots: true}).Crawl(server.URL+"/", 2, false)
+	if len(results) != 5 {
+		t.Errorf("cross-host crawl gave %d pages, want 5 with the other host's", len(results))
+	}
+
+	results, _ = (&Scraper{IgnoreRobots: true}).Crawl(server.URL+"/", 0, true)
+	if got := resultPaths(results, server.URL); !slices.Equal(got, []string{"/"}) {
+		t.Errorf("depth 0 crawled %q, want only the seed", got)
+	}
+}
+
+func TestCrawlParser(t *testing.T) {
+	server, _, _ := linkServer(t, map[string][]string{"/": {"/a"}, "/a": nil})
+
+	// A parser that can't extract links is replaced, with a warning
+	var logs bytes.Buffer
+	s := &Scraper{Parser: titleParser{}, IgnoreRobots: true, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	results, _ := s.Crawl(server.URL+"/", 1, true)
+	if len(results) != 2 {
+		t.Errorf("crawl with a custom parser gave %d pages, want links still followed", len(results))
+	}
+	if !strings.Contains(logs.String(), "Crawling with DefaultParser in place of the custom parser") {
+		t.Errorf("no warning about the custom parser in:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,31 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+)
+
+// backgroundDecl matches background and background-image declarations and
+// captures their value
+var backgroundDecl = regexp.MustCompile(`(?i)background(?:-image)?\s*:\s*([^;}]*)`)
+
+// cssURL matches url(...) references in their double quoted, single quoted
+// and unquoted forms
+var cssURL = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+
+// parseBackgroundImages returns the URLs referenced by background-image (or
+// background shorthand) declarations in a block of CSS. A declaration with
+// several layers, e.g. "url(a.png), url(b.png)", yields every URL.
+func parseBackgroundImages(css string) []string {
+	urls := []string{}
+	for _, decl := range backgroundDecl.FindAllStringSubmatch(css, -1) {
+		for _, match := range cssURL.FindAllStringSubmatch(decl[1], -1) {
+			// Only one of the three alternatives captures anything
+			ref := strings.TrimSpace(match[1] + match[2] + match[3])
+			if ref != "" {
+				urls = append(urls, ref)
+			}
+		}
+	}
+	return urls
+}
@@ -0,0 +1,44 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseBackgroundImages(t *testing.T) {
+	tests := []struct {
+		css  string
+		want []string
+	}{
+		{`background-image: url("/img/hero.jpg")`, []string{"/img/hero.jpg"}},
+		{`BACKGROUND: #fff url('bg.png') no-repeat`, []string{"bg.png"}},
+		{`background-image:url( /img/a.png ), url(/img/b.png); color: red`, []string{"/img/a.png", "/img/b.png"}},
+		{`.card { background-image: url(card.webp) } .x { border-image: url(no.png) }`, []string{"card.webp"}},
+		{`background: none; background-image: url("")`, []string{}},
+		{`color: red`, []string{}},
+	}
+	for _, tt := range tests {
+		if got := parseBackgroundImages(tt.css); !slices.Equal(got, tt.want) {
+			t.Errorf("parseBackgroundImages(%q) = %q, want %q", tt.css, got, tt.want)
+		}
+	}
+}
+
+func TestBackgroundImages(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><head>
+<style>.hero { background-image: url(/img/hero.jpg); }</style>
+</head><body>
+<div style="background: url('banner.png') center"></div>
+<img src="/img/inline.jpg">
+</body></html>`)
+
+	for _, want := range []string{
+		"https://example.com/img/hero.jpg",
+		"https://example.com/articles/banner.png",
+		"https://example.com/img/inline.jpg",
+	} {
+		if !slices.Contains(data.ImageURLs, want) {
+			t.Errorf("ImageURLs = %q, missing %q", data.ImageURLs, want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+)
+
+// dedupeStrings returns list without repeated entries, keeping the first
+// occurrence of each
+func dedupeStrings(list []string) []string {
+	return dedupeStringsBy(list, nil)
+}
+
+// dedupeStringsBy is like dedupeStrings but treats entries with the same key
+// as repeats; a nil key compares the entries themselves
+func dedupeStringsBy(list []string, key func(string) string) []string {
+	seen := make(map[string]bool, len(list))
+	unique := []string{}
+	for _, item := range list {
+		k := item
+		if key != nil {
+			k = key(item)
+		}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, item)
+	}
+	return unique
+}
+
+// dedupeImages returns images without repeated URLs, keeping the first
+// occurrence of each
+func dedupeImages(images []Image) []Image {
+	seen := make(map[string]bool, len(images))
+	unique := []Image{}
+	for _, img := range images {
+		if seen[img.URL] {
+			continue
+		}
+		seen[img.URL] = true
+		unique = append(unique, img)
+	}
+	return unique
+}
+
+// keepImages removes every image URL of res for which keep returns false,
+// from ImageURLs and from the lists that detail them
+func keepImages(res *MediaData, keep func(string) bool) {
+	filter := func(list []string) []string {
+		kept := []string{}
+		for _, imgURL := range list {
+			if keep(imgURL) {
+				kept = append(kept, imgURL)
+			}
+		}
+		return kept
+	}
+
+	res.ImageURLs = filter(res.ImageURLs)
+	res.SocialImages = filter(res.SocialImages)
+	res.StructuredImages = filter(res.StructuredImages)
+
+	images := []Image{}
+	for _, img := range res.Images {
+		if keep(img.URL) {
+			images = append(images, img)
+		}
+	}
+	res.Images = images
+}
+
+// PageSet remembers pages by canonical URL, or by their own URL when they
+// declare none
+type PageSet map[string]bool
+
+// Add records res and reports whether no earlier page had the same key
+func (seen PageSet) Add(res MediaData) bool {
+	key := res.CanonicalURL
+	if key == "" {
+		key = res.URL
+	}
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+// FinalURLSet remembers pages by the URL they were served from after any
+// redirects, so sitemap URLs redirecting to the same page count once
+type FinalURLSet map[string]bool
+
+// Add records res and reports whether no earlier page ended up at the same
+// final URL
+func (seen FinalURLSet) Add(res MediaData) bool {
+	key := res.FinalURL
+	if key == "" {
+		key = res.URL
+	}
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+// ImageSet remembers the images of the pages passed to Dedupe. The zero
+// value is ready to use.
+type ImageSet struct {
+	// Key gives the key images are compared by; nil compares their URLs
+	Key func(imgURL string) string
+
+	seen map[string]bool
+}
+
+// Dedupe removes the images of res already listed for an earlier page.
+// Images repeated within the page itself are left to per-page dedupe.
+func (s *ImageSet) Dedupe(res *MediaData) {
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	page := make(map[string]bool)
+	keepImages(res, func(imgURL string) bool {
+		key := imgURL
+		if s.Key != nil {
+			key = s.Key(imgURL)
+		}
+		if s.seen[key] && !page[key] {
+			return false
+		}
+		s.seen[key] = true
+		page[key] = true
+		return true
+	})
+}
+
+// imageSetHash returns the hex SHA-256 of the page's distinct image URLs,
+// sorted so that the order they appear in doesn't matter
+func imageSetHash(res MediaData) string {
+	urls := dedupeStrings(res.ImageURLs)
+	sort.Strings(urls)
+	hash := sha256.New()
+	for _, imgURL := range urls {
+		// A separator that can't occur in a URL keeps the boundaries unambiguous
+		hash.Write([]byte(imgURL))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ImageSets maps each image set hash to the first page that had it
+type ImageSets map[string]string
+
+// Mark sets ImageSetHash on res when it has images, and points DuplicateOf at
+// the first earlier page with the same image set
+func (first ImageSets) Mark(res *MediaData) {
+	// Pages without images would all look alike
+	if len(res.ImageURLs) == 0 {
+		return
+	}
+	res.ImageSetHash = imageSetHash(*res)
+	if url, ok := first[res.ImageSetHash]; ok {
+		res.DuplicateOf = url
+		return
+	}
+	first[res.ImageSetHash] = res.URL
+}
@@ -0,0 +1,140 @@
+package scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestDedupeWithinPage(t *testing.T) {
+	page := `<html><body>
+<img src="/img/a.jpg"><img src="/img/b.jpg"><img src="/img/a.jpg"><img src="https://example.com/img/b.jpg">
+</body></html>`
+
+	data := parsePage(t, DefaultParser{}, page)
+	want := []string{"https://example.com/img/a.jpg", "https://example.com/img/b.jpg"}
+	if !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+	if len(data.Images) != 2 {
+		t.Errorf("got %d Images, want 2", len(data.Images))
+	}
+
+	data = parsePage(t, DefaultParser{KeepDuplicates: true}, page)
+	if len(data.ImageURLs) != 4 {
+		t.Errorf("with KeepDuplicates got %d ImageURLs, want all 4", len(data.ImageURLs))
+	}
+}
+
+func TestImageSetDedupe(t *testing.T) {
+	pages := []MediaData{
+		{URL: "https://example.com/1", ImageURLs: []string{"logo.png", "a.jpg"}, Images: []Image{{URL: "logo.png"}, {URL: "a.jpg"}}},
+		{URL: "https://example.com/2", ImageURLs: []string{"logo.png", "b.jpg", "b.jpg"}, Images: []Image{{URL: "logo.png"}, {URL: "b.jpg"}}},
+		{URL: "https://example.com/3", ImageURLs: []string{"a.jpg", "logo.png"}},
+	}
+	var seen ImageSet
+	for i := range pages {
+		seen.Dedupe(&pages[i])
+	}
+
+	// Repeats within a page are left alone; only images from earlier pages go
+	want := [][]string{{"logo.png", "a.jpg"}, {"b.jpg", "b.jpg"}, {}}
+	for i, page := range pages {
+		if !slices.Equal(page.ImageURLs, want[i]) {
+			t.Errorf("page %d ImageURLs = %q, want %q", i+1, page.ImageURLs, want[i])
+		}
+	}
+	if len(pages[1].Images) != 1 || pages[1].Images[0].URL != "b.jpg" {
+		t.Errorf("page 2 Images = %+v, want just b.jpg", pages[1].Images)
+	}
+}
+
+func TestImageSetsMark(t *testing.T) {
+	pages := []MediaData{
+		{URL: "https://example.com/article", ImageURLs: []string{"a.jpg", "b.jpg"}},
+		{URL: "https://example.com/article/amp", ImageURLs: []string{"b.jpg", "a.jpg", "a.jpg"}},
+		{URL: "https://example.com/other", ImageURLs: []string{"a.jpg", "c.jpg"}},
+		{URL: "https://example.com/empty1", ImageURLs: []string{}},
+		{URL: "https://example.com/empty2", ImageURLs: []string{}},
+	}
+	first := ImageSets{}
+	var summary Summary
+	for i := range pages {
+		first.Mark(&pages[i])
+		summary.Add(pages[i])
+	}
+
+	// The order and repeats of the images don't matter
+	if pages[1].DuplicateOf != pages[0].URL || pages[1].ImageSetHash != pages[0].ImageSetHash {
+		t.Errorf("AMP page DuplicateOf = %q, want %q with the same hash", pages[1].DuplicateOf, pages[0].URL)
+	}
+	if pages[0].DuplicateOf != "" || pages[2].DuplicateOf != "" || pages[2].ImageSetHash == pages[0].ImageSetHash {
+		t.Errorf("pages with different images were flagged: %+v", pages)
+	}
+	// Pages without images are never flagged
+	if pages[4].DuplicateOf != "" || pages[4].ImageSetHash != "" {
+		t.Errorf("page without images got hash %q and DuplicateOf %q", pages[4].ImageSetHash, pages[4].DuplicateOf)
+	}
+	if summary.DuplicatePages != 1 {
+		t.Errorf("summary counts %d duplicate pages, want 1", summary.DuplicatePages)
+	}
+}
+
+func TestPageSet(t *testing.T) {
+	seen := PageSet{}
+	pages := []struct {
+		res  MediaData
+		want bool
+	}{
+		{MediaData{URL: "https://example.com/story", CanonicalURL: "https://example.com/story"}, true},
+		{MediaData{URL: "https://example.com/story?amp=1", CanonicalURL: "https://example.com/story"}, false},
+		{MediaData{URL: "https://example.com/print/story", CanonicalURL: "https://example.com/story"}, false},
+		{MediaData{URL: "https://example.com/other"}, true},
+		{MediaData{URL: "https://example.com/other"}, false},
+		{MediaData{URL: "https://example.com/story"}, false},
+	}
+	for _, page := range pages {
+		if got := seen.Add(page.res); got != page.want {
+			t.Errorf("Add(%s) = %v, want %v", page.res.URL, got, page.want)
+		}
+	}
+}
+
+func TestFinalURLSet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old", "/moved":
+			http.Redirect(w, r, "/story", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="%s.png"></body></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	urls := []string{server.URL + "/old", server.URL + "/moved", server.URL + "/other"}
+	results, errs := (&Scraper{Concurrency: 1, IgnoreRobots: true}).Scrape(urls)
+	if len(errs) != 0 || len(results) != 3 {
+		t.Fatalf("Scrape = %d results, %v, want 3", len(results), errs)
+	}
+
+	seen := FinalURLSet{}
+	var kept []string
+	for _, res := range results {
+		if seen.Add(res) {
+			kept = append(kept, res.FinalURL)
+		}
+	}
+	slices.Sort(kept)
+	if want := []string{server.URL + "/other", server.URL + "/story"}; !slices.Equal(kept, want) {
+		t.Errorf("kept pages %q, want %q", kept, want)
+	}
+
+	// Results from parsers that don't set FinalURL are compared by URL
+	seen = FinalURLSet{}
+	if !seen.Add(MediaData{URL: "https://example.com/a"}) || seen.Add(MediaData{URL: "https://example.com/a"}) {
+		t.Error("pages without a FinalURL weren't compared by URL")
+	}
+}
@@ -0,0 +1,86 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// URLKey is the dedupe key, for DefaultParser.DedupeKey and ImageSet.Key,
+// that compares images by their exact URL
+func URLKey(imgURL string) string {
+	return imgURL
+}
+
+// URLWithoutQuery is the dedupe key that compares images by their URL
+// without the query string or fragment, so size and cache-busting variants
+// of one image count as repeats
+func URLWithoutQuery(imgURL string) string {
+	parsed, err := url.Parse(imgURL)
+	if err != nil {
+		return imgURL
+	}
+	parsed.RawQuery = ""
+	parsed.ForceQuery = false
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	return parsed.String()
+}
+
+// ContentHashKey returns a key that compares images by the SHA-256 of their
+// contents, fetched with the scraper's settings, so the same file served
+// from different URLs counts as a repeat. Each URL is fetched once and the
+// key is safe for concurrent use. Images that can't be fetched, or are over
+// the scraper's MaxImageSize, are compared by URL instead, as are all images
+// once ctx is done. Failed image fetches don't count against the circuit
+// breaker of their host.
+func (s *Scraper) ContentHashKey(ctx context.Context) func(imgURL string) string {
+	var mu sync.Mutex
+	hashes := make(map[string]string)
+	return func(imgURL string) string {
+		mu.Lock()
+		hash, ok := hashes[imgURL]
+		mu.Unlock()
+		if ok {
+			return hash
+		}
+
+		hash = imgURL
+		if sum, err := s.contentHash(ctx, imgURL); err != nil {
+			s.log().Debug("Image not hashed, comparing by URL", "url", imgURL, "err", err)
+		} else {
+			hash = "sha256:" + sum
+		}
+		mu.Lock()
+		hashes[imgURL] = hash
+		mu.Unlock()
+		return hash
+	}
+}
+
+// contentHash returns the hex SHA-256 of the image at imgURL
+func (s *Scraper) contentHash(ctx context.Context, imgURL string) (string, error) {
+	resp, err := s.send(ctx, http.MethodGet, imgURL, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	hash := sha256.New()
+	limit := s.maxImageSize()
+	n, err := io.Copy(hash, io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return "", err
+	}
+	if n > limit {
+		return "", errSkippedImage
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
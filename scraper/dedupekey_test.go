@@ -0,0 +1,94 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupeKeys(t *testing.T) {
+	page := `<html><body>
+<img src="/a.png?w=100">
+<img src="/a.png?w=200#large">
+<img src="/b.png">
+<img src="/a.png?w=100">
+</body></html>`
+	tests := []struct {
+		name string
+		key  func(string) string
+		want []string
+	}{
+		{"URLKey", URLKey, []string{"https://example.com/a.png?w=100", "https://example.com/a.png?w=200#large", "https://example.com/b.png"}},
+		{"URLWithoutQuery", URLWithoutQuery, []string{"https://example.com/a.png?w=100", "https://example.com/b.png"}},
+	}
+	for _, tt := range tests {
+		data := parsePage(t, DefaultParser{DedupeKey: tt.key}, page)
+		if !slices.Equal(data.ImageURLs, tt.want) {
+			t.Errorf("%s: ImageURLs = %q, want %q", tt.name, data.ImageURLs, tt.want)
+		}
+
+		// The same keys apply across pages
+		set := ImageSet{Key: tt.key}
+		first := MediaData{ImageURLs: []string{"https://example.com/c.png?v=1"}}
+		second := MediaData{ImageURLs: []string{"https://example.com/c.png?v=2"}}
+		set.Dedupe(&first)
+		set.Dedupe(&second)
+		if repeated := len(second.ImageURLs) == 0; repeated != (tt.name == "URLWithoutQuery") {
+			t.Errorf("%s: second page kept %q", tt.name, second.ImageURLs)
+		}
+	}
+}
+
+func TestContentHashKey(t *testing.T) {
+	img := pngBytes(t)
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/a.png", "/copy-of-a.png":
+			w.Write(img)
+		case "/other.png":
+			w.Write(append(img, 0))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Hour}
+	s := &Scraper{Breaker: breaker}
+	key := s.ContentHashKey(context.Background())
+	a, copyOfA, other, missing := server.URL+"/a.png", server.URL+"/copy-of-a.png", server.URL+"/other.png", server.URL+"/missing.png"
+
+	if key(a) != key(copyOfA) {
+		t.Error("the same image at two URLs has different keys")
+	}
+	if key(a) == key(other) {
+		t.Error("different images have the same key")
+	}
+	if got := key(missing); got != missing {
+		t.Errorf("key of a missing image = %q, want its URL", got)
+	}
+	key(a)
+	if fetches["/a.png"] != 1 {
+		t.Errorf("%s was fetched %d times, want once", a, fetches["/a.png"])
+	}
+	// The 404 isn't held against the host's pages
+	if !breaker.allow(breakerHost(server.URL)) {
+		t.Error("a failed image fetch opened the host's breaker")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fresh := server.URL + "/a.png?fresh"
+	if got := s.ContentHashKey(ctx)(fresh); got != fresh {
+		t.Errorf("key after cancel = %q, want the URL", got)
+	}
+}
@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// errSkippedImage marks images that were deliberately not saved
+var errSkippedImage = errors.New("skipped")
+
+// DownloadImages fetches every unique URL in imageURLs and saves it into
+// dir. Inline data: images, responses that are not images and images larger
+// than DefaultScraper's MaxImageSize are skipped. Errors for individual
+// images are collected and returned together once all downloads have
+// finished.
+func DownloadImages(imageURLs []string, dir string, concurrency int) error {
+	return DefaultScraper.downloadImages(imageURLs, dir, concurrency)
+}
+
+// downloadImages is DownloadImages with the scraper's settings
+func (s *Scraper) downloadImages(imageURLs []string, dir string, concurrency int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	// Fetch each image once, however many pages use it
+	var remote []string
+	for _, imgURL := range dedupeStrings(imageURLs) {
+		if !strings.HasPrefix(imgURL, "data:") {
+			remote = append(remote, imgURL)
+		}
+	}
+	imageURLs = remote
+
+	jobs := make(chan string)
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	concurrency = s.boundConcurrency(concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for imgURL := range jobs {
+				err := s.downloadImage(imgURL, dir)
+				if errors.Is(err, errSkippedImage) {
+					s.log().Info("Skipping image", "url", imgURL, "reason", err)
+					continue
+				}
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("downloading %s: %w", imgURL, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, imgURL := range imageURLs {
+		jobs <- imgURL
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// downloadImage saves a single image into dir
+func (s *Scraper) downloadImage(imgURL, dir string) error {
+	resp, err := s.makeRequest(context.Background(), imgURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		return fmt.Errorf("content type %q is not an image: %w", contentType, errSkippedImage)
+	}
+	limit := s.maxImageSize()
+	if resp.ContentLength > limit {
+		return fmt.Errorf("size %d exceeds limit of %d bytes: %w", resp.ContentLength, limit, errSkippedImage)
+	}
+
+	file, err := createUniqueFile(dir, imageFilename(imgURL))
+	if err != nil {
+		return err
+	}
+
+	// Read one byte past the limit to detect oversized bodies that didn't
+	// advertise a Content-Length
+	written, err := io.Copy(file, io.LimitReader(resp.Body, limit+1))
+	closeErr := file.Close()
+	if err == nil && written > limit {
+		err = fmt.Errorf("body exceeds limit of %d bytes: %w", limit, errSkippedImage)
+	}
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+// imageFilename derives a safe file name from the last element of the URL path
+func imageFilename(imgURL string) string {
+	name := ""
+	if parsed, err := url.Parse(imgURL); err == nil {
+		name = strings.TrimLeft(path.Base(parsed.Path), "/")
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		name = "image"
+	}
+	return name
+}
+
+// createUniqueFile creates name inside dir, adding a numeric suffix (photo-1.jpg,
+// photo-2.jpg, ...) when a file with that name already exists
+func createUniqueFile(dir, name string) (*os.File, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 1; ; i++ {
+		file, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if !errors.Is(err, os.ErrExist) {
+			return file, err
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
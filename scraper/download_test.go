@@ -0,0 +1,106 @@
+package scraper
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// pngBytes encodes a small blank PNG
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadImages(t *testing.T) {
+	img := pngBytes(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.png", "/other/photo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(img)
+		case "/page.png":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// The images of two pages, sharing photo.png
+	imageURLs := []string{
+		server.URL + "/photo.png",
+		server.URL + "/page.png",
+		"data:image/gif;base64,R0lGODlhAQABAAAAACw=",
+		server.URL + "/photo.png",
+		server.URL + "/other/photo.png",
+		server.URL + "/missing.png",
+	}
+	dir := filepath.Join(t.TempDir(), "images")
+	if err := DownloadImages(imageURLs, dir, 2); err == nil {
+		t.Error("DownloadImages returned no error for the missing image")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	// The repeated URL is fetched once; the second photo.png gets a suffix
+	if want := []string{"photo-1.png", "photo.png"}; !slices.Equal(names, want) {
+		t.Fatalf("files = %q, want %q", names, want)
+	}
+	for _, name := range names {
+		saved, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(saved, img) {
+			t.Errorf("%s holds %d bytes that differ from the served PNG", name, len(saved))
+		}
+	}
+}
+
+func TestDownloadImagesTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngBytes(t))
+	}))
+	defer server.Close()
+
+	s := &Scraper{MaxImageSize: 10}
+	dir := t.TempDir()
+	if err := s.downloadImages([]string{server.URL + "/big.png"}, dir, 1); err != nil {
+		t.Errorf("downloadImages = %v, want oversized images skipped without error", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("oversized image was saved as %s", entries[0].Name())
+	}
+}
+
+func TestImageFilename(t *testing.T) {
+	tests := []struct{ imgURL, want string }{
+		{"https://example.com/img/photo.jpg?w=100", "photo.jpg"},
+		{"https://example.com/", "image"},
+		{"https://example.com/img/a:b.png", "a_b.png"},
+	}
+	for _, tt := range tests {
+		if got := imageFilename(tt.imgURL); got != tt.want {
+			t.Errorf("imageFilename(%q) = %q, want %q", tt.imgURL, got, tt.want)
+		}
+	}
+}
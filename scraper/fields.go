@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// mediaFields are the JSON names of the MediaData fields, in the order they
+// are written out
+var mediaFields = jsonFieldNames(reflect.TypeOf(MediaData{}))
+
+// jsonFieldNames lists the JSON names of the fields of struct type t
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ParseFields splits a comma separated list of MediaData JSON field names
+// such as "url,image_urls", rejecting names that aren't fields. An empty
+// list gives nil, which selects every field.
+func ParseFields(list string) ([]string, error) {
+	var fields []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(mediaFields, name) {
+			return nil, fmt.Errorf("unknown field %q, want one of %s", name, strings.Join(mediaFields, ", "))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// wantsField reports whether a writer selecting fields includes name; no
+// fields means all of them
+func wantsField(fields []string, name string) bool {
+	return len(fields) == 0 || slices.Contains(fields, name)
+}
+
+// marshalFields encodes res as a JSON object with only the selected fields,
+// in MediaData order. Fields left out by omitempty stay out.
+func marshalFields(res MediaData, fields []string) ([]byte, error) {
+	full, err := json.Marshal(res)
+	if err != nil || len(fields) == 0 {
+		return full, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(full, &values); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range mediaFields {
+		value, ok := values[name]
+		if !ok || !slices.Contains(fields, name) {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields(" URL, image_urls,,")
+	if err != nil {
+		t.Fatalf("ParseFields: %v", err)
+	}
+	if want := []string{"url", "image_urls"}; !slices.Equal(fields, want) {
+		t.Errorf("ParseFields = %q, want %q", fields, want)
+	}
+	if fields, err := ParseFields(""); fields != nil || err != nil {
+		t.Errorf("ParseFields of nothing = %q, %v, want nil", fields, err)
+	}
+	if _, err := ParseFields("url,images_urls"); err == nil {
+		t.Error("ParseFields accepted an unknown field")
+	}
+}
+
+func TestMarshalFields(t *testing.T) {
+	res := sampleResults()[0]
+	// Fields come out in MediaData order, not the order asked for
+	got, err := marshalFields(res, []string{"status_code", "url"})
+	if err != nil {
+		t.Fatalf("marshalFields: %v", err)
+	}
+	if want := `{"url":"https://example.com/a","status_code":200}`; string(got) != want {
+		t.Errorf("marshalFields = %s, want %s", got, want)
+	}
+}
+
+func TestWriterFields(t *testing.T) {
+	results := sampleResults()
+	tests := []struct {
+		format string
+		fields []string
+		want   string
+	}{
+		{"text", []string{"url", "image_urls"}, "URL: https://example.com/a\nImages:\n" +
+			"- https://example.com/1.jpg (alt: \"A dog\", 300x200, status: 200)\n- https://example.com/2.png\n\n" +
+			"URL: https://example.com/b\nImages:\n\n"},
+		{"ndjson", []string{"url", "title"}, `{"url":"https://example.com/a","title":"Page A"}` + "\n" +
+			`{"url":"https://example.com/b","title":""}` + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := OutputFormats[tt.format].NewWriter(&buf, tt.fields).Write(results); err != nil {
+			t.Fatalf("%s Write: %v", tt.format, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s with fields %q = %q, want %q", tt.format, tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	if err := OutputFormats["csv"].CheckFields([]string{"url", "image_urls"}); err != nil {
+		t.Errorf("csv CheckFields(url, image_urls): %v", err)
+	}
+	if err := OutputFormats["csv"].CheckFields([]string{"title"}); err == nil {
+		t.Error("csv CheckFields(title) succeeded, want an error")
+	}
+	if err := OutputFormats["json"].CheckFields([]string{"icons"}); err != nil {
+		t.Errorf("json CheckFields(icons): %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ImageFilter decides which extracted image URLs are kept
+type ImageFilter struct {
+	// Include, when non-empty, keeps only images with one of these extensions
+	Include []string
+	// Exclude drops images with any of these extensions
+	Exclude []string
+	// DropDataURIs drops inline data: images
+	DropDataURIs bool
+	// SameHost keeps only images served from the host of their page
+	SameHost bool
+	// Hosts keeps only images served from these hosts or their subdomains,
+	// in addition to the page's own host when SameHost is set
+	Hosts []string
+	// MinWidth and MinHeight drop images whose img tag declares a smaller
+	// width or height. Images that don't declare one are kept unless
+	// RequireDimensions is set.
+	MinWidth, MinHeight int
+	RequireDimensions   bool
+}
+
+// ParseExtensions splits a comma separated extension list such as
+// ".jpg,PNG, webp" into normalized extensions like ".jpg", ".png", ".webp"
+func ParseExtensions(list string) []string {
+	var extensions []string
+	for _, ext := range strings.Split(list, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions
+}
+
+// imageExtension returns the lowercased extension of the URL path, ignoring
+// any query string or fragment
+func imageExtension(imgURL string) string {
+	parsed, err := url.Parse(imgURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(path.Ext(parsed.Path))
+}
+
+// Allow reports whether imgURL passes the filter
+func (f ImageFilter) Allow(imgURL string) bool {
+	if strings.HasPrefix(imgURL, "data:") {
+		if f.DropDataURIs {
+			return false
+		}
+		// Inline images have no extension to match against
+		return len(f.Include) == 0
+	}
+
+	ext := imageExtension(imgURL)
+	for _, excluded := range f.Exclude {
+		if ext == excluded {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, included := range f.Include {
+		if ext == included {
+			return true
+		}
+	}
+	return false
+}
+
+// hostAllowed reports whether imgURL is served from a host the filter keeps
+// for a page on pageHost. Inline data: images count as the page's own.
+func (f ImageFilter) hostAllowed(imgURL, pageHost string) bool {
+	if !f.SameHost && len(f.Hosts) == 0 {
+		return true
+	}
+	if strings.HasPrefix(imgURL, "data:") {
+		return true
+	}
+	parsed, err := url.Parse(imgURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if f.SameHost && host == strings.ToLower(pageHost) {
+		return true
+	}
+	for _, allowed := range f.Hosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// dimensionsAllowed reports whether an image declared as width by height,
+// either 0 when not declared, is large enough for the filter
+func (f ImageFilter) dimensionsAllowed(width, height int) bool {
+	for _, dim := range []struct{ size, min int }{{width, f.MinWidth}, {height, f.MinHeight}} {
+		if dim.min <= 0 {
+			continue
+		}
+		if dim.size == 0 && f.RequireDimensions || dim.size > 0 && dim.size < dim.min {
+			return false
+		}
+	}
+	return true
+}
+
+// Active reports whether the filter would drop anything at all
+func (f ImageFilter) Active() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0 || f.DropDataURIs || f.SameHost || len(f.Hosts) > 0 ||
+		f.MinWidth > 0 || f.MinHeight > 0
+}
+
+// Apply removes the images of res that the filter drops
+func (f ImageFilter) Apply(res *MediaData) {
+	pageHost := ""
+	if parsed, err := url.Parse(res.URL); err == nil {
+		pageHost = parsed.Hostname()
+	}
+	// Sizes are only known for images from img tags; the first tag
+	// declaring one counts when an image appears more than once
+	sizes := make(map[string]Image)
+	for _, img := range res.Images {
+		size := sizes[img.URL]
+		if size.Width == 0 {
+			size.Width = img.Width
+		}
+		if size.Height == 0 {
+			size.Height = img.Height
+		}
+		sizes[img.URL] = size
+	}
+	keepImages(res, func(imgURL string) bool {
+		size := sizes[imgURL]
+		return f.Allow(imgURL) && f.hostAllowed(imgURL, pageHost) && f.dimensionsAllowed(size.Width, size.Height)
+	})
+}
+
+// ParseHosts splits a comma separated host list, lowercased
+func ParseHosts(list string) []string {
+	var hosts []string
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
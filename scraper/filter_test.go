@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseExtensions(t *testing.T) {
+	got := ParseExtensions(".jpg,PNG, webp,,")
+	want := []string{".jpg", ".png", ".webp"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseExtensions = %q, want %q", got, want)
+	}
+}
+
+func TestImageFilterExtensions(t *testing.T) {
+	tests := []struct {
+		filter ImageFilter
+		imgURL string
+		want   bool
+	}{
+		{ImageFilter{}, "https://example.com/a.svg", true},
+		{ImageFilter{Include: []string{".jpg", ".png"}}, "https://example.com/a.JPG", true},
+		{ImageFilter{Include: []string{".jpg", ".png"}}, "https://example.com/a.png?w=100#top", true},
+		{ImageFilter{Include: []string{".jpg", ".png"}}, "https://example.com/a.gif", false},
+		{ImageFilter{Include: []string{".jpg"}}, "https://example.com/image", false},
+		{ImageFilter{Exclude: []string{".svg"}}, "https://example.com/icon.svg", false},
+		{ImageFilter{Exclude: []string{".svg"}}, "https://example.com/photo.jpg", true},
+		{ImageFilter{Include: []string{".svg"}, Exclude: []string{".svg"}}, "https://example.com/icon.svg", false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Allow(tt.imgURL); got != tt.want {
+			t.Errorf("%+v.Allow(%q) = %v, want %v", tt.filter, tt.imgURL, got, tt.want)
+		}
+	}
+}
+
+func TestImageFilterApply(t *testing.T) {
+	res := MediaData{
+		URL:          "https://example.com/page",
+		ImageURLs:    []string{"https://example.com/a.jpg", "https://example.com/b.svg", "https://example.com/c.png"},
+		Images:       []Image{{URL: "https://example.com/a.jpg"}, {URL: "https://example.com/b.svg"}},
+		SocialImages: []string{"https://example.com/b.svg"},
+	}
+	ImageFilter{Exclude: []string{".svg"}}.Apply(&res)
+
+	want := []string{"https://example.com/a.jpg", "https://example.com/c.png"}
+	if !slices.Equal(res.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", res.ImageURLs, want)
+	}
+	if len(res.Images) != 1 || len(res.SocialImages) != 0 {
+		t.Errorf("Images = %+v and SocialImages = %q, want the svg dropped from both", res.Images, res.SocialImages)
+	}
+}
+
+func TestImageFilterHosts(t *testing.T) {
+	images := []string{
+		"https://example.com/a.jpg",
+		"https://EXAMPLE.com/b.jpg",
+		"https://cdn.example.com/c.jpg",
+		"https://ads.tracker.net/d.gif",
+		"https://img.partner.org/e.png",
+		"data:image/gif;base64,R0lGODlhAQABAAAAACw=",
+	}
+	tests := []struct {
+		filter ImageFilter
+		want   []int
+	}{
+		{ImageFilter{}, []int{0, 1, 2, 3, 4, 5}},
+		{ImageFilter{SameHost: true}, []int{0, 1, 5}},
+		{ImageFilter{SameHost: true, Hosts: ParseHosts("Partner.org, ")}, []int{0, 1, 4, 5}},
+		// Without SameHost only the listed hosts are kept, not the page's
+		{ImageFilter{Hosts: ParseHosts("cdn.example.com")}, []int{2, 5}},
+	}
+	for _, tt := range tests {
+		res := MediaData{URL: "https://example.com/page", ImageURLs: slices.Clone(images)}
+		tt.filter.Apply(&res)
+		var want []string
+		for _, i := range tt.want {
+			want = append(want, images[i])
+		}
+		if !slices.Equal(res.ImageURLs, want) {
+			t.Errorf("%+v: ImageURLs = %q, want %q", tt.filter, res.ImageURLs, want)
+		}
+	}
+}
+
+func TestImageFilterDimensions(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><body>
+<img src="/pixel.gif" width="1" height="1">
+<img src="/icon.png" width="32" height="32">
+<img src="/banner.jpg" width="800" height="90">
+<img src="/photo.jpg" width="640px" height="480">
+<img src="/unknown.jpg">
+<img src="/wide.png" width="1200">
+</body></html>`)
+	tests := []struct {
+		filter ImageFilter
+		want   []string
+	}{
+		{ImageFilter{}, []string{"/pixel.gif", "/icon.png", "/banner.jpg", "/photo.jpg", "/unknown.jpg", "/wide.png"}},
+		{ImageFilter{MinWidth: 100}, []string{"/banner.jpg", "/photo.jpg", "/unknown.jpg", "/wide.png"}},
+		{ImageFilter{MinWidth: 100, MinHeight: 100}, []string{"/photo.jpg", "/unknown.jpg", "/wide.png"}},
+		{ImageFilter{MinWidth: 100, MinHeight: 100, RequireDimensions: true}, []string{"/photo.jpg"}},
+		{ImageFilter{MinWidth: 100, RequireDimensions: true}, []string{"/banner.jpg", "/photo.jpg", "/wide.png"}},
+	}
+	for _, tt := range tests {
+		res := data
+		res.ImageURLs = slices.Clone(data.ImageURLs)
+		res.Images = slices.Clone(data.Images)
+		tt.filter.Apply(&res)
+		var paths []string
+		for _, imgURL := range res.ImageURLs {
+			paths = append(paths, strings.TrimPrefix(imgURL, "https://example.com"))
+		}
+		if !slices.Equal(paths, tt.want) {
+			t.Errorf("%+v: images = %q, want %q", tt.filter, paths, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package scraper
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HAR types cover the parts of the HAR 1.2 format filled in for each page:
+// http://www.softwareishard.com/blog/har-12-spec/
+type (
+	harLog struct {
+		Log struct {
+			Version string     `json:"version"`
+			Creator harCreator `json:"creator"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+
+	harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	harEntry struct {
+		StartedDateTime time.Time   `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+		// Error is set, with a zero status, for requests that got no response
+		Error string `json:"_error,omitempty"`
+	}
+
+	harRequest struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Headers     []harHeader `json:"headers"`
+		QueryString []harHeader `json:"queryString"`
+		Cookies     []harHeader `json:"cookies"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int         `json:"bodySize"`
+	}
+
+	harResponse struct {
+		Status      int         `json:"status"`
+		StatusText  string      `json:"statusText"`
+		HTTPVersion string      `json:"httpVersion"`
+		Headers     []harHeader `json:"headers"`
+		Cookies     []harHeader `json:"cookies"`
+		Content     harContent  `json:"content"`
+		RedirectURL string      `json:"redirectURL"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int64       `json:"bodySize"`
+	}
+
+	harHeader struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	harContent struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+	}
+
+	harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+// HARRecorder collects an HTTP Archive entry for every page the scraper
+// fetches, so a crawl can be inspected or replayed later. It is safe for
+// concurrent use.
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// milliseconds converts d to the fractional milliseconds HAR uses
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harHeaders lists header in a stable order
+func harHeaders(header http.Header) []harHeader {
+	list := []harHeader{}
+	for name, values := range header {
+		for _, value := range values {
+			list = append(list, harHeader{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// redacted stands in for header and cookie values left out of the archive
+const redacted = "[redacted]"
+
+// redactHeaders returns a copy of header with the values of the named
+// headers replaced by redacted
+func redactHeaders(header http.Header, names ...string) http.Header {
+	header = header.Clone()
+	for _, name := range names {
+		values := header[http.CanonicalHeaderKey(name)]
+		for i := range values {
+			values[i] = redacted
+		}
+	}
+	return header
+}
+
+// harRequestFor describes req, or a bare GET of rawURL when there is none
+func harRequestFor(req *http.Request, rawURL string) harRequest {
+	entry := harRequest{
+		Method:      http.MethodGet,
+		URL:         rawURL,
+		HTTPVersion: "HTTP/1.1",
+		Headers:     []harHeader{},
+		QueryString: []harHeader{},
+		Cookies:     []harHeader{},
+		HeadersSize: -1,
+	}
+	if parsed, err := url.Parse(rawURL); err == nil {
+		for name, values := range parsed.Query() {
+			for _, value := range values {
+				entry.QueryString = append(entry.QueryString, harHeader{Name: name, Value: value})
+			}
+		}
+	}
+	if req != nil {
+		entry.Method = req.Method
+		entry.URL = req.URL.String()
+		// Keep credentials and session cookies out of the archive
+		entry.Headers = harHeaders(redactHeaders(req.Header, "Authorization", "Cookie"))
+		for _, cookie := range req.Cookies() {
+			entry.Cookies = append(entry.Cookies, harHeader{Name: cookie.Name, Value: redacted})
+		}
+	}
+	return entry
+}
+
+// record adds the entry for a response whose headers arrived after wait and
+// whose body, bodySize bytes of which were read, was done after total
+func (h *HARRecorder) record(rawURL string, started time.Time, wait, total time.Duration, resp *http.Response, bodySize int64) {
+	entry := harEntry{
+		StartedDateTime: started,
+		Time:            milliseconds(total),
+		Request:         harRequestFor(resp.Request, rawURL),
+		Response: harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Headers:     harHeaders(redactHeaders(resp.Header, "Set-Cookie")),
+			Cookies:     []harHeader{},
+			Content: harContent{
+				Size:     bodySize,
+				MimeType: resp.Header.Get("Content-Type"),
+			},
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    bodySize,
+		},
+		Timings: harTimings{
+			Wait:    milliseconds(wait),
+			Receive: milliseconds(total - wait),
+		},
+	}
+	for _, cookie := range resp.Cookies() {
+		entry.Response.Cookies = append(entry.Response.Cookies, harHeader{Name: cookie.Name, Value: redacted})
+	}
+	h.add(entry)
+}
+
+// recordError adds the entry for a request that failed without a response
+func (h *HARRecorder) recordError(rawURL string, started time.Time, err error) {
+	total := time.Since(started)
+	h.add(harEntry{
+		StartedDateTime: started,
+		Time:            milliseconds(total),
+		Request:         harRequestFor(nil, rawURL),
+		Response: harResponse{
+			Headers: []harHeader{},
+			Cookies: []harHeader{},
+			// HAR uses -1 for sizes that aren't known
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: harTimings{Wait: milliseconds(total)},
+		Error:   err.Error(),
+	})
+}
+
+func (h *HARRecorder) add(entry harEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+}
+
+// WriteTo writes the recorded entries as a HAR document, ordered by start time
+func (h *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	var log harLog
+	log.Log.Version = "1.2"
+	log.Log.Creator = harCreator{Name: robotsUserAgent, Version: "1.0"}
+	log.Log.Entries = append([]harEntry{}, h.entries...)
+	h.mu.Unlock()
+	sort.SliceStable(log.Log.Entries, func(i, j int) bool {
+		return log.Log.Entries[i].StartedDateTime.Before(log.Log.Entries[j].StartedDateTime)
+	})
+
+	contents, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(contents, '\n'))
+	return int64(n), err
+}
+
+// WriteFile writes the HAR document to path
+func (h *HARRecorder) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := h.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
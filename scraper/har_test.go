@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "server-secret"})
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><img src="/a.png"></body></html>`)
+	}))
+	defer server.Close()
+
+	har := &HARRecorder{}
+	s := &Scraper{
+		IgnoreRobots: true,
+		HAR:          har,
+		Headers:      http.Header{"Authorization": {"Bearer token-secret"}, "Cookie": {"id=client-secret"}},
+	}
+	urls := []string{server.URL + "/found?page=1", server.URL + "/missing", "http://127.0.0.1:1/refused"}
+	s.Scrape(urls)
+
+	var out bytes.Buffer
+	if _, err := har.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	for _, secret := range []string{"server-secret", "token-secret", "client-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("HAR contains %q", secret)
+		}
+	}
+
+	var doc harLog
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("HAR isn't valid JSON: %v", err)
+	}
+	if doc.Log.Version != "1.2" {
+		t.Errorf("version = %q, want 1.2", doc.Log.Version)
+	}
+	statuses := make(map[string]int)
+	for _, entry := range doc.Log.Entries {
+		if _, seen := statuses[entry.Request.URL]; seen {
+			t.Errorf("%s has more than one entry", entry.Request.URL)
+		}
+		statuses[entry.Request.URL] = entry.Response.Status
+	}
+	want := map[string]int{urls[0]: http.StatusOK, urls[1]: http.StatusNotFound, urls[2]: 0}
+	if fmt.Sprint(statuses) != fmt.Sprint(want) {
+		t.Errorf("entry statuses = %v, want %v", statuses, want)
+	}
+
+	for _, entry := range doc.Log.Entries {
+		switch entry.Request.URL {
+		case urls[0]:
+			if entry.Response.BodySize <= 0 || entry.Response.Content.MimeType != "text/html" {
+				t.Errorf("page entry has body size %d and type %q", entry.Response.BodySize, entry.Response.Content.MimeType)
+			}
+			if len(entry.Request.QueryString) != 1 || entry.Request.QueryString[0] != (harHeader{"page", "1"}) {
+				t.Errorf("page query string = %+v", entry.Request.QueryString)
+			}
+			if len(entry.Response.Cookies) != 1 || entry.Response.Cookies[0] != (harHeader{"session", redacted}) {
+				t.Errorf("page response cookies = %+v, want session redacted", entry.Response.Cookies)
+			}
+		case urls[2]:
+			if entry.Error == "" || entry.Response.BodySize != -1 {
+				t.Errorf("refused entry = %+v, want an error and unknown size", entry)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Icon is a site icon declared by a <link> element
+type Icon struct {
+	URL string `json:"url"`
+	// Rel is the icon's link relation, e.g. "icon" or "apple-touch-icon"
+	Rel string `json:"rel"`
+	// Sizes is the declared sizes attribute, e.g. "32x32" or "any"
+	Sizes string `json:"sizes,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// iconRels are the link relations that declare a site icon. "shortcut icon"
+// is matched by its "icon" token.
+var iconRels = map[string]bool{
+	"icon":                         true,
+	"apple-touch-icon":             true,
+	"apple-touch-icon-precomposed": true,
+}
+
+// extractIcons returns the icons declared in the document's <link> elements
+// with their hrefs resolved against base
+func extractIcons(doc *goquery.Document, base *url.URL) []Icon {
+	icons := []Icon{}
+	doc.Find("link[rel][href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		if strings.TrimSpace(href) == "" {
+			return
+		}
+
+		// rel is a space separated list of tokens, matched case-insensitively
+		rel, _ := s.Attr("rel")
+		for _, token := range strings.Fields(strings.ToLower(rel)) {
+			if !iconRels[token] {
+				continue
+			}
+			sizes, _ := s.Attr("sizes")
+			iconType, _ := s.Attr("type")
+			icons = append(icons, Icon{
+				URL:   resolveURL(base, href),
+				Rel:   token,
+				Sizes: strings.TrimSpace(sizes),
+				Type:  strings.TrimSpace(iconType),
+			})
+			return
+		}
+	})
+	return icons
+}
+
+// dedupeIcons removes repeated declarations of the same icon URL and size,
+// keeping the first
+func dedupeIcons(icons []Icon) []Icon {
+	type key struct{ url, sizes string }
+	seen := make(map[key]bool, len(icons))
+	unique := []Icon{}
+	for _, icon := range icons {
+		k := key{icon.URL, icon.Sizes}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, icon)
+	}
+	return unique
+}
@@ -0,0 +1,32 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIcons(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><head>
+<link rel="Shortcut Icon" href="/favicon.ico">
+<link rel="icon" type="image/png" sizes="32x32" href="/icon-32.png">
+<link rel="icon" type="image/png" sizes="192x192" href="/icon-192.png">
+<link rel="icon" sizes="32x32" href="/icon-32.png">
+<link rel="apple-touch-icon" sizes="180x180" href="touch.png">
+<link rel="stylesheet" href="/site.css">
+<link rel="icon" href=" ">
+</head><body></body></html>`)
+
+	want := []Icon{
+		{URL: "https://example.com/favicon.ico", Rel: "icon"},
+		{URL: "https://example.com/icon-32.png", Rel: "icon", Sizes: "32x32", Type: "image/png"},
+		{URL: "https://example.com/icon-192.png", Rel: "icon", Sizes: "192x192", Type: "image/png"},
+		{URL: "https://example.com/articles/touch.png", Rel: "apple-touch-icon", Sizes: "180x180"},
+	}
+	if !slices.Equal(data.Icons, want) {
+		t.Errorf("Icons = %+v, want %+v", data.Icons, want)
+	}
+	// Icons are kept apart from the page's images
+	if len(data.ImageURLs) != 0 {
+		t.Errorf("ImageURLs = %q, want none", data.ImageURLs)
+	}
+}
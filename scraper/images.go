@@ -0,0 +1,625 @@
+package scraper
+
+import (
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// MediaData holds information about extracted images
+type MediaData struct {
+	URL             string   `json:"url"`
+	RequestedURL    string   `json:"requested_url"`           // URL as it was requested, before redirects
+	FinalURL        string   `json:"final_url"`               // URL the content was served from, after redirects
+	CanonicalURL    string   `json:"canonical_url,omitempty"` // URL given by <link rel="canonical">
+	ImageURLs       []string `json:"image_urls"`
+	Images          []Image  `json:"images"`        // details of the ImageURLs that came from img tags
+	SocialImages    []string `json:"social_images"` // og:image and twitter:image URLs, also included in ImageURLs
+	StatusCode      int      `json:"status_code"`
+	Title           string   `json:"title"` // the document <title>, with runs of whitespace collapsed
+	MetaDescription string   `json:"meta_description"`
+	Skipped         bool     `json:"skipped,omitempty"`        // the response was not HTML, or over MaxContentLength, so it wasn't parsed
+	Unchanged       bool     `json:"unchanged,omitempty"`      // the page was not modified, so the cached data was used
+	Truncated       bool     `json:"truncated,omitempty"`      // the body was larger than Scraper.MaxPageSize and only its start was parsed
+	Icons           []Icon   `json:"icons"`                    // favicons and touch icons declared by <link> elements
+	VideoURLs       []string `json:"video_urls,omitempty"`     // <video> sources, when DefaultParser.Video is set
+	AudioURLs       []string `json:"audio_urls,omitempty"`     // <audio> sources, when DefaultParser.Audio is set
+	Links           []string `json:"links,omitempty"`          // page links, filled in by LinkParser
+	ImageSetHash    string   `json:"image_set_hash,omitempty"` // SHA-256 of the page's image URLs, set by ImageSets.Mark
+	DuplicateOf     string   `json:"duplicate_of,omitempty"`   // earlier page with the same image set
+
+	// StructuredImages are the image URLs given by the JSON-LD structured
+	// data, also included in ImageURLs
+	StructuredImages []string `json:"structured_images"`
+
+	// ImageStatuses maps each image URL to the status code it was served
+	// with, or 0 when it couldn't be fetched at all. Only set when the
+	// images were checked with VerifyImages.
+	ImageStatuses map[string]int `json:"image_statuses,omitempty"`
+	// ImageInfo maps image URLs to the format and size read from the image
+	// files. Only set when the images were probed with ProbeImages.
+	ImageInfo map[string]ImageInfo `json:"image_info,omitempty"`
+
+	// Timing of the page request and of parsing it, the response's
+	// Content-Length (-1 when the server didn't send one) and the number of
+	// body bytes that were read
+	FetchDuration time.Duration `json:"fetch_duration_ns"`
+	ParseDuration time.Duration `json:"parse_duration_ns"`
+	ContentLength int64         `json:"content_length"`
+	BodySize      int64         `json:"body_size"`
+}
+
+// Image holds the details declared on an img tag. Width and Height are 0 when
+// the tag doesn't declare them.
+type Image struct {
+	URL    string `json:"url"`
+	Alt    string `json:"alt,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Parser defines the parsing interface
+type Parser interface {
+	GetMediaData(resp *http.Response) (MediaData, error)
+}
+
+// DefaultParser is the default image parser. Its zero value extracts every
+// image on a page, with repeats on the same page removed.
+type DefaultParser struct {
+	// KeepDuplicates keeps every occurrence of an image URL on a page
+	KeepDuplicates bool
+	// PreferredTypes lists image MIME types such as "image/avif" from most
+	// to least wanted. When set, each <picture> element contributes only its
+	// first <source> of the most preferred type offered, or its fallback img
+	// when it offers none of them.
+	PreferredTypes []string
+	// Video and Audio also extract the sources of <video> and <audio>
+	// elements into VideoURLs and AudioURLs
+	Video bool
+	Audio bool
+	// BaseURL stands in for the page URL when a response has no Request,
+	// as with responses built by hand
+	BaseURL *url.URL
+	// Normalizer, when set, rewrites image URLs before duplicates are
+	// removed so that tracking variants of one URL collapse together
+	Normalizer *URLNormalizer
+	// DedupeKey, when set, gives the key repeated images are recognised by
+	// instead of their exact URL, e.g. URLWithoutQuery. The first of the
+	// images sharing a key is kept.
+	DedupeKey func(imgURL string) string
+	// Logger receives messages about skipped responses and markup; nil
+	// means the package logger
+	Logger *slog.Logger
+}
+
+// log returns the parser's logger
+func (d DefaultParser) log() *slog.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return Logger
+}
+
+// responseURL returns the URL the response was served from. Responses built
+// by hand may have no request, in which case base is used instead; it may be
+// nil too, leaving relative image URLs unresolved.
+func responseURL(resp *http.Response, base *url.URL) *url.URL {
+	if resp.Request != nil && resp.Request.URL != nil {
+		return resp.Request.URL
+	}
+	return base
+}
+
+// urlString is u.String(), or empty for a nil URL
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// ParseImageTypes splits a comma separated format list such as "avif, webp"
+// into MIME types like "image/avif", "image/webp". Full MIME types are kept
+// as given.
+func ParseImageTypes(list string) []string {
+	var types []string
+	for _, t := range strings.Split(list, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if !strings.Contains(t, "/") {
+			t = "image/" + t
+		}
+		types = append(types, t)
+	}
+	return types
+}
+
+// preferredSource returns the <source> of picture whose type comes earliest
+// in types
+func preferredSource(picture *goquery.Selection, types []string) (*goquery.Selection, bool) {
+	for _, want := range types {
+		var found *goquery.Selection
+		picture.ChildrenFiltered("source").EachWithBreak(func(i int, s *goquery.Selection) bool {
+			sourceType, _ := s.Attr("type")
+			if mediaType, _, err := mime.ParseMediaType(sourceType); err == nil && mediaType == want {
+				found = s
+				return false
+			}
+			return true
+		})
+		if found != nil {
+			return found, true
+		}
+	}
+	return nil, false
+}
+
+// lazySrcAttrs lists the attributes lazy-loading scripts commonly use to
+// hold the real image URL while src is empty or a placeholder.
+var lazySrcAttrs = []string{"data-src", "data-lazy-src", "data-original"}
+
+// imageSource returns the URL an img tag will eventually display. It prefers
+// src, falling back to the lazy-load attributes when src is missing, empty or
+// an inline data: placeholder.
+func imageSource(s *goquery.Selection) (string, bool) {
+	src, exists := s.Attr("src")
+	src = strings.TrimSpace(src)
+	if exists && src != "" && !strings.HasPrefix(src, "data:") {
+		return src, true
+	}
+
+	for _, attr := range lazySrcAttrs {
+		if lazy, ok := s.Attr(attr); ok && strings.TrimSpace(lazy) != "" {
+			return strings.TrimSpace(lazy), true
+		}
+	}
+
+	// Keep the placeholder if that is all the tag has
+	return src, exists && src != ""
+}
+
+// parseSrcset returns the candidate URLs from a srcset attribute such as
+// "img-320.jpg 320w, img-640.jpg 640w" or "a.png, a@2x.png 2x". It follows the
+// HTML parsing rules: a URL runs until whitespace, so commas inside the URL
+// (e.g. in a query string) are kept, and only trailing commas end a candidate.
+func parseSrcset(srcset string) []string {
+	urls := []string{}
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+
+	i := 0
+	for i < len(srcset) {
+		// Skip separators between candidates
+		for i < len(srcset) && (isSpace(srcset[i]) || srcset[i] == ',') {
+			i++
+		}
+		if i >= len(srcset) {
+			break
+		}
+
+		// Collect the URL up to the next whitespace
+		start := i
+		for i < len(srcset) && !isSpace(srcset[i]) {
+			i++
+		}
+		candidate := srcset[start:i]
+
+		// A trailing comma means there is no descriptor for this candidate
+		trimmed := strings.TrimRight(candidate, ",")
+		if trimmed != "" {
+			urls = append(urls, trimmed)
+		}
+		if len(trimmed) != len(candidate) {
+			continue
+		}
+
+		// Skip the width/density descriptor (e.g. "640w" or "2x"), which
+		// runs until the next comma outside of parentheses
+		depth := 0
+		for i < len(srcset) {
+			c := srcset[i]
+			if c == '(' {
+				depth++
+			} else if c == ')' && depth > 0 {
+				depth--
+			} else if c == ',' && depth == 0 {
+				break
+			}
+			i++
+		}
+	}
+	return urls
+}
+
+// srcsetURLs returns the absolute candidate URLs from an element's srcset
+// and data-srcset attributes
+func srcsetURLs(s *goquery.Selection, base *url.URL) []string {
+	urls := []string{}
+	for _, attr := range []string{"srcset", "data-srcset"} {
+		if srcset, ok := s.Attr(attr); ok {
+			for _, candidate := range parseSrcset(srcset) {
+				urls = append(urls, resolveURL(base, candidate))
+			}
+		}
+	}
+	return urls
+}
+
+// resolveURL turns a possibly relative link into an absolute URL using the
+// page it was found on. Root-relative, path-relative and protocol-relative
+// (//cdn.example.com/x.jpg) links are all handled by ResolveReference. Links
+// that fail to parse are returned unchanged.
+func resolveURL(base *url.URL, ref string) string {
+	parsed, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || base == nil {
+		return ref
+	}
+	return base.ResolveReference(parsed).String()
+}
+
+// metaDescription returns the page's meta description, falling back to the
+// Open Graph description when the page has no plain description tag
+func metaDescription(doc *goquery.Document) string {
+	if content, ok := doc.Find("meta[name^=description]").Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	content, _ := doc.Find(`meta[property="og:description"]`).Attr("content")
+	return strings.TrimSpace(content)
+}
+
+// pageTitle returns the document's <title>, ignoring the titles of inline
+// SVG images
+func pageTitle(doc *goquery.Document) string {
+	title := doc.Find("title").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return s.ParentsFiltered("svg").Length() == 0
+	}).First().Text()
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// documentBase returns the URL relative links in the document resolve
+// against: its first <base href>, itself resolved against pageURL, or pageURL
+// when there is none
+func documentBase(doc *goquery.Document, pageURL *url.URL) *url.URL {
+	href, ok := doc.Find("base[href]").First().Attr("href")
+	if !ok {
+		return pageURL
+	}
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return pageURL
+	}
+	if pageURL == nil {
+		return ref
+	}
+	return pageURL.ResolveReference(ref)
+}
+
+// canonicalURL returns the page's <link rel="canonical"> href resolved
+// against base, or empty when the page declares none
+func canonicalURL(doc *goquery.Document, base *url.URL) string {
+	canonical := ""
+	doc.Find("link[rel][href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		rel, _ := s.Attr("rel")
+		for _, token := range strings.Fields(strings.ToLower(rel)) {
+			href, _ := s.Attr("href")
+			if token == "canonical" && strings.TrimSpace(href) != "" {
+				canonical = resolveURL(base, href)
+				return false
+			}
+		}
+		return true
+	})
+	return canonical
+}
+
+// parseDimension reads a declared width or height such as "300" or "300px".
+// Relative sizes like percentages can't be known and give 0.
+func parseDimension(value string) int {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// requestedURL walks back through a redirect chain to the URL that was
+// originally requested
+func requestedURL(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return urlString(req.URL)
+}
+
+// isHTML reports whether the response declares an HTML content type. A
+// missing Content-Type is given the benefit of the doubt.
+func isHTML(resp *http.Response) bool {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// truncatingReader reads at most remaining bytes from r and records whether
+// r had more to give
+type truncatingReader struct {
+	r         io.Reader
+	remaining int64
+	truncated bool
+}
+
+// Read implements io.Reader
+func (t *truncatingReader) Read(p []byte) (int, error) {
+	if t.remaining <= 0 {
+		// Probe for one more byte to tell a body that is exactly the limit
+		// from one that goes past it
+		var probe [1]byte
+		if n, _ := t.r.Read(probe[:]); n > 0 {
+			t.truncated = true
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > t.remaining {
+		p = p[:t.remaining]
+	}
+	n, err := t.r.Read(p)
+	t.remaining -= int64(n)
+	return n, err
+}
+
+// parseDocument parses the HTML in r, served with contentType
+func parseDocument(r io.Reader, contentType string) (*goquery.Document, error) {
+	// Creates a goquery Document from the HTML, transcoding it to UTF-8
+	// first. The charset comes from the Content-Type, a byte order mark or a
+	// <meta charset> tag, in that order.
+	body, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(body)
+}
+
+// skippedMediaData describes a non-HTML response from pageURL, which was not
+// parsed
+func (d DefaultParser) skippedMediaData(resp *http.Response, pageURL *url.URL) MediaData {
+	d.log().Info("Skipping non-HTML response", "url", urlString(pageURL), "content_type", resp.Header.Get("Content-Type"))
+	return unparsedMediaData(resp, pageURL)
+}
+
+// unparsedMediaData describes a response from pageURL that was not parsed
+func unparsedMediaData(resp *http.Response, pageURL *url.URL) MediaData {
+	return MediaData{
+		URL:              urlString(pageURL),
+		RequestedURL:     requestedURL(resp.Request),
+		FinalURL:         urlString(pageURL),
+		ImageURLs:        []string{},
+		Images:           []Image{},
+		SocialImages:     []string{},
+		StructuredImages: []string{},
+		Icons:            []Icon{},
+		StatusCode:       resp.StatusCode,
+		Skipped:          true,
+		ContentLength:    resp.ContentLength,
+	}
+}
+
+// GetMediaData extracts all image URLs from the response
+func (d DefaultParser) GetMediaData(resp *http.Response) (MediaData, error) {
+	data, _, err := d.responseMediaData(resp)
+	return data, err
+}
+
+// responseMediaData is like GetMediaData but also returns the parsed
+// document, which is nil for responses that aren't HTML
+func (d DefaultParser) responseMediaData(resp *http.Response) (MediaData, *goquery.Document, error) {
+	defer resp.Body.Close()
+	pageURL := responseURL(resp, d.BaseURL)
+	if !isHTML(resp) {
+		return d.skippedMediaData(resp, pageURL), nil, nil
+	}
+	data, doc, err := d.readMediaData(resp.Body, resp.Header.Get("Content-Type"), pageURL)
+	if err != nil {
+		return MediaData{}, nil, err
+	}
+	data.RequestedURL = requestedURL(resp.Request)
+	data.StatusCode = resp.StatusCode
+	return data, doc, nil
+}
+
+// GetMediaDataFromReader is like GetMediaData but parses HTML read from r,
+// such as a saved page, resolving its links against pageURL. Without a
+// response the result has no status code, and the charset comes from the
+// document alone.
+func (d DefaultParser) GetMediaDataFromReader(r io.Reader, pageURL string) (MediaData, error) {
+	base := d.BaseURL
+	if pageURL != "" {
+		parsed, err := url.Parse(pageURL)
+		if err != nil {
+			return MediaData{}, err
+		}
+		base = parsed
+	}
+	data, _, err := d.readMediaData(r, "", base)
+	return data, err
+}
+
+// readMediaData parses the HTML in r, served from pageURL with contentType,
+// and extracts its media data, returning the parsed document too
+func (d DefaultParser) readMediaData(r io.Reader, contentType string, pageURL *url.URL) (MediaData, *goquery.Document, error) {
+	doc, err := parseDocument(r, contentType)
+	if err != nil {
+		return MediaData{}, nil, err
+	}
+	return d.documentMediaData(doc, pageURL), doc, nil
+}
+
+// documentMediaData extracts the images and page details from a parsed
+// document, leaving out what only the response can tell
+func (d DefaultParser) documentMediaData(doc *goquery.Document, pageURL *url.URL) MediaData {
+
+	// Relative image links are resolved against the page they came from, or
+	// against the page's <base href> when it has one
+	base := documentBase(doc, pageURL)
+	imageURLs := []string{}
+	images := []Image{}
+
+	// With preferred types, pick the wanted <source> of each <picture> up
+	// front so it can stand in for the picture's fallback img
+	preferred := make(map[*html.Node][]string)
+	if len(d.PreferredTypes) > 0 {
+		doc.Find("picture").Each(func(i int, picture *goquery.Selection) {
+			if source, ok := preferredSource(picture, d.PreferredTypes); ok {
+				preferred[picture.Get(0)] = srcsetURLs(source, base)
+			}
+		})
+	}
+
+	// Searches the goquery Document for img tags and the src link
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		var elementURLs []string
+		if urls, ok := preferred[s.Get(0).Parent]; ok {
+			elementURLs = urls
+		} else {
+			src, exists := imageSource(s)
+			// If the src link exists, add it to the imageURLs string list
+			if exists {
+				elementURLs = append(elementURLs, resolveURL(base, src))
+			}
+
+			// Responsive images list further candidates in srcset
+			elementURLs = append(elementURLs, srcsetURLs(s, base)...)
+		}
+
+		// Every candidate shares the alt text and size declared on the tag
+		alt, _ := s.Attr("alt")
+		width, _ := s.Attr("width")
+		height, _ := s.Attr("height")
+		for _, elementURL := range elementURLs {
+			images = append(images, Image{
+				URL:    elementURL,
+				Alt:    strings.TrimSpace(alt),
+				Width:  parseDimension(width),
+				Height: parseDimension(height),
+			})
+		}
+		imageURLs = append(imageURLs, elementURLs...)
+	})
+
+	// <picture> elements offer alternatives (often WebP/AVIF) in <source>
+	// tags next to the fallback img found above. With preferred types the
+	// choice between them has already been made.
+	if len(d.PreferredTypes) == 0 {
+		doc.Find("picture source").Each(func(i int, s *goquery.Selection) {
+			imageURLs = append(imageURLs, srcsetURLs(s, base)...)
+		})
+	}
+
+	// Decorative and hero images are often set through CSS instead, either in
+	// inline style attributes or in <style> blocks
+	doc.Find("[style]").Each(func(i int, s *goquery.Selection) {
+		style, _ := s.Attr("style")
+		for _, ref := range parseBackgroundImages(style) {
+			imageURLs = append(imageURLs, resolveURL(base, ref))
+		}
+	})
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		for _, ref := range parseBackgroundImages(s.Text()) {
+			imageURLs = append(imageURLs, resolveURL(base, ref))
+		}
+	})
+
+	// Open Graph and Twitter Card meta tags advertise the page's main image
+	socialImages := []string{}
+	doc.Find(`meta[property="og:image"], meta[name="twitter:image"]`).Each(func(i int, s *goquery.Selection) {
+		content, exists := s.Attr("content")
+		if exists && strings.TrimSpace(content) != "" {
+			socialImages = append(socialImages, resolveURL(base, content))
+		}
+	})
+	imageURLs = append(imageURLs, socialImages...)
+
+	// Articles often name their lead images in JSON-LD structured data
+	structuredImages := extractStructuredImages(doc, base, d.log())
+	imageURLs = append(imageURLs, structuredImages...)
+
+	// Site icons are kept apart from the page's images
+	icons := extractIcons(doc, base)
+
+	// The same image often appears several times on one page
+	if d.Normalizer != nil {
+		d.Normalizer.normalizeAll(imageURLs)
+		d.Normalizer.normalizeAll(socialImages)
+		d.Normalizer.normalizeAll(structuredImages)
+		for i := range images {
+			images[i].URL = d.Normalizer.Normalize(images[i].URL)
+		}
+	}
+	if !d.KeepDuplicates {
+		imageURLs = dedupeStringsBy(imageURLs, d.DedupeKey)
+		socialImages = dedupeStrings(socialImages)
+		structuredImages = dedupeStrings(structuredImages)
+		images = dedupeImages(images)
+		icons = dedupeIcons(icons)
+	}
+
+	// Construct the MediaData struct with new info
+	result := MediaData{
+		URL:              urlString(pageURL),
+		FinalURL:         urlString(pageURL),
+		ImageURLs:        imageURLs,
+		Images:           images,
+		SocialImages:     socialImages,
+		Icons:            icons,
+		StructuredImages: structuredImages,
+	}
+	// Images dropped for sharing a key with an earlier one lose their
+	// details too
+	if !d.KeepDuplicates && d.DedupeKey != nil {
+		listed := make(map[string]bool, len(imageURLs))
+		for _, imgURL := range imageURLs {
+			listed[imgURL] = true
+		}
+		keepImages(&result, func(imgURL string) bool { return listed[imgURL] })
+	}
+	result.Title = pageTitle(doc)
+	result.MetaDescription = metaDescription(doc)
+	result.CanonicalURL = canonicalURL(doc, base)
+
+	// Video and audio sources, kept apart from the images
+	if d.Video {
+		result.VideoURLs = mediaSources(doc, "video", base)
+	}
+	if d.Audio {
+		result.AudioURLs = mediaSources(doc, "audio", base)
+	}
+	if d.Normalizer != nil {
+		d.Normalizer.normalizeAll(result.VideoURLs)
+		d.Normalizer.normalizeAll(result.AudioURLs)
+	}
+	if !d.KeepDuplicates {
+		result.VideoURLs = dedupeStrings(result.VideoURLs)
+		result.AudioURLs = dedupeStrings(result.AudioURLs)
+	}
+	return result
+}
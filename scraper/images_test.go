@@ -0,0 +1,443 @@
+package scraper
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testPageURL is the URL the fixture pages are served from
+const testPageURL = "https://example.com/articles/page.html"
+
+// htmlResponse builds the response of a GET of pageURL serving body as HTML
+func htmlResponse(t *testing.T, pageURL, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+// parsePage runs parser over body served from testPageURL
+func parsePage(t *testing.T, parser Parser, body string) MediaData {
+	t.Helper()
+	data, err := parser.GetMediaData(htmlResponse(t, testPageURL, body))
+	if err != nil {
+		t.Fatalf("GetMediaData: %v", err)
+	}
+	return data
+}
+
+func TestLazyLoadedImages(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><body>
+<img data-src="/img/lazy.jpg">
+<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" data-lazy-src="/img/placeholder-replaced.jpg">
+<img src="" data-original="/img/original.jpg">
+<img src="/img/eager.jpg" data-src="/img/ignored.jpg">
+</body></html>`)
+
+	want := []string{
+		"https://example.com/img/lazy.jpg",
+		"https://example.com/img/placeholder-replaced.jpg",
+		"https://example.com/img/original.jpg",
+		"https://example.com/img/eager.jpg",
+	}
+	if !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+}
+
+func TestSrcset(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><body>
+<img src="/img/hero-320.jpg" srcset="/img/hero-320.jpg 320w, /img/hero-640.jpg 640w,
+	/img/hero-1280.jpg 1280w" sizes="(max-width: 600px) 320px, 640px">
+<img srcset="/img/icon.png, /img/icon@2x.png 2x">
+<img data-srcset="/img/resize?w=100,h=50 1x, /img/resize?w=200,h=100 2x">
+</body></html>`)
+
+	want := []string{
+		"https://example.com/img/hero-320.jpg",
+		"https://example.com/img/hero-640.jpg",
+		"https://example.com/img/hero-1280.jpg",
+		"https://example.com/img/icon.png",
+		"https://example.com/img/icon@2x.png",
+		"https://example.com/img/resize?w=100,h=50",
+		"https://example.com/img/resize?w=200,h=100",
+	}
+	if !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		srcset string
+		want   []string
+	}{
+		{"", []string{}},
+		{"a.jpg", []string{"a.jpg"}},
+		{"a.jpg 1x, b.jpg 2x", []string{"a.jpg", "b.jpg"}},
+		{"a.jpg,b.jpg", []string{"a.jpg,b.jpg"}},
+		{"a.jpg, b.jpg", []string{"a.jpg", "b.jpg"}},
+		{" a.jpg 100w ,\n b.jpg 200w ", []string{"a.jpg", "b.jpg"}},
+	}
+	for _, tt := range tests {
+		if got := parseSrcset(tt.srcset); !slices.Equal(got, tt.want) {
+			t.Errorf("parseSrcset(%q) = %q, want %q", tt.srcset, got, tt.want)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	base, err := url.Parse(testPageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref, want string
+	}{
+		{"https://cdn.example.net/a.jpg", "https://cdn.example.net/a.jpg"},
+		{"/img/a.jpg", "https://example.com/img/a.jpg"},
+		{"img/a.jpg", "https://example.com/articles/img/a.jpg"},
+		{"../img/a.jpg", "https://example.com/img/a.jpg"},
+		{"//cdn.example.net/a.jpg", "https://cdn.example.net/a.jpg"},
+		{"  /img/padded.jpg ", "https://example.com/img/padded.jpg"},
+	}
+	for _, tt := range tests {
+		if got := resolveURL(base, tt.ref); got != tt.want {
+			t.Errorf("resolveURL(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+
+	// Without a page URL the link can only be returned as it is
+	if got := resolveURL(nil, "img/a.jpg"); got != "img/a.jpg" {
+		t.Errorf("resolveURL(nil, %q) = %q, want it unchanged", "img/a.jpg", got)
+	}
+}
+
+func TestSocialImages(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><head>
+<meta property="og:image" content="https://cdn.example.com/og.jpg">
+<meta name="twitter:image" content="/img/card.jpg">
+<meta property="og:image" content="  ">
+</head><body><img src="/img/body.jpg"></body></html>`)
+
+	wantSocial := []string{"https://cdn.example.com/og.jpg", "https://example.com/img/card.jpg"}
+	if !slices.Equal(data.SocialImages, wantSocial) {
+		t.Errorf("SocialImages = %q, want %q", data.SocialImages, wantSocial)
+	}
+	wantAll := append([]string{"https://example.com/img/body.jpg"}, wantSocial...)
+	if !slices.Equal(data.ImageURLs, wantAll) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, wantAll)
+	}
+}
+
+func TestImageDetails(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><body>
+<img src="/img/dog.jpg" alt=" A dog " width="300" height="200px">
+<img src="/img/banner.jpg" width="100%">
+<img src="/img/plain.jpg">
+</body></html>`)
+
+	want := []Image{
+		{URL: "https://example.com/img/dog.jpg", Alt: "A dog", Width: 300, Height: 200},
+		{URL: "https://example.com/img/banner.jpg"},
+		{URL: "https://example.com/img/plain.jpg"},
+	}
+	if !slices.Equal(data.Images, want) {
+		t.Errorf("Images = %+v, want %+v", data.Images, want)
+	}
+}
+
+func TestParseDimension(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"300", 300},
+		{" 300px ", 300},
+		{"50%", 0},
+		{"-1", 0},
+		{"auto", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseDimension(tt.value); got != tt.want {
+			t.Errorf("parseDimension(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPictureSources(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><body>
+<picture>
+  <source media="(min-width: 800px)" srcset="/img/wide.avif 1x, /img/wide@2x.avif 2x" type="image/avif">
+  <source srcset="/img/photo.webp" type="image/webp">
+  <img src="/img/photo.jpg" alt="Photo">
+</picture>
+</body></html>`)
+
+	want := []string{
+		"https://example.com/img/photo.jpg",
+		"https://example.com/img/wide.avif",
+		"https://example.com/img/wide@2x.avif",
+		"https://example.com/img/photo.webp",
+	}
+	if !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+}
+
+func TestNonHTMLSkipped(t *testing.T) {
+	tests := []struct {
+		contentType string
+		skipped     bool
+	}{
+		{"text/html; charset=utf-8", false},
+		{"application/xhtml+xml", false},
+		{"", false},
+		{"application/pdf", true},
+		{"application/json", true},
+		{"image/png", true},
+		{"not a media type;;", true},
+	}
+	for _, tt := range tests {
+		resp := htmlResponse(t, testPageURL, `<html><body><img src="/img/a.jpg"></body></html>`)
+		resp.Header.Set("Content-Type", tt.contentType)
+		data, err := DefaultParser{}.GetMediaData(resp)
+		if err != nil {
+			t.Fatalf("GetMediaData of %q: %v", tt.contentType, err)
+		}
+		if data.Skipped != tt.skipped {
+			t.Errorf("Content-Type %q: Skipped = %v, want %v", tt.contentType, data.Skipped, tt.skipped)
+		}
+		if tt.skipped && (len(data.ImageURLs) != 0 || data.URL != testPageURL || data.StatusCode != http.StatusOK) {
+			t.Errorf("Content-Type %q: got %+v, want just the URL and status", tt.contentType, data)
+		}
+	}
+}
+
+func TestLatin1Page(t *testing.T) {
+	// "Café" and "Crème brûlée" in ISO-8859-1
+	page := "<html><head><title>Caf\xe9</title><meta name=\"description\" content=\"Cr\xe8me br\xfbl\xe9e\"></head>" +
+		"<body><img src=\"/img/cr\xe8me.jpg\" alt=\"Cr\xe8me\"></body></html>"
+
+	resp := htmlResponse(t, testPageURL, page)
+	resp.Header.Set("Content-Type", "text/html; charset=ISO-8859-1")
+	data, err := DefaultParser{}.GetMediaData(resp)
+	if err != nil {
+		t.Fatalf("GetMediaData: %v", err)
+	}
+	if data.Title != "Café" || data.MetaDescription != "Crème brûlée" {
+		t.Errorf("Title = %q and MetaDescription = %q, want them decoded from Latin-1", data.Title, data.MetaDescription)
+	}
+	if len(data.Images) != 1 || data.Images[0].Alt != "Crème" {
+		t.Errorf("Images = %+v, want the alt text decoded", data.Images)
+	}
+
+	// Without a charset in the header the <meta charset> tag is used
+	resp = htmlResponse(t, testPageURL, `<meta charset="iso-8859-1">`+page)
+	resp.Header.Set("Content-Type", "text/html")
+	if data, err = (DefaultParser{}).GetMediaData(resp); err != nil || data.Title != "Café" {
+		t.Errorf("with <meta charset> Title = %q, %v, want %q", data.Title, err, "Café")
+	}
+}
+
+func TestPreferredTypes(t *testing.T) {
+	page := `<html><body>
+<picture>
+  <source srcset="/img/a.webp" type="image/webp">
+  <source srcset="/img/a.avif 1x, /img/a@2x.avif 2x" type="IMAGE/AVIF">
+  <img src="/img/a.jpg">
+</picture>
+<picture>
+  <source srcset="/img/b.webp" type="image/webp">
+  <img src="/img/b.jpg">
+</picture>
+<picture>
+  <source srcset="/img/c.png" type="image/png">
+  <img src="/img/c.jpg">
+</picture>
+</body></html>`
+
+	tests := []struct {
+		types []string
+		want  []string
+	}{
+		{ParseImageTypes("avif, webp"), []string{
+			"https://example.com/img/a.avif", "https://example.com/img/a@2x.avif",
+			"https://example.com/img/b.webp",
+			"https://example.com/img/c.jpg",
+		}},
+		{ParseImageTypes("webp"), []string{
+			"https://example.com/img/a.webp",
+			"https://example.com/img/b.webp",
+			"https://example.com/img/c.jpg",
+		}},
+	}
+	for _, tt := range tests {
+		data := parsePage(t, DefaultParser{PreferredTypes: tt.types}, page)
+		if !slices.Equal(data.ImageURLs, tt.want) {
+			t.Errorf("with %q ImageURLs = %q, want %q", tt.types, data.ImageURLs, tt.want)
+		}
+	}
+}
+
+func TestParseImageTypes(t *testing.T) {
+	got := ParseImageTypes("AVIF, webp,,image/jxl")
+	want := []string{"image/avif", "image/webp", "image/jxl"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseImageTypes = %q, want %q", got, want)
+	}
+}
+
+func TestNilRequest(t *testing.T) {
+	page := `<html><body><img src="/img/a.jpg"><img src="https://cdn.example.net/b.jpg"></body></html>`
+	resp := htmlResponse(t, testPageURL, page)
+	resp.Request = nil
+
+	// Without a request or BaseURL nothing can resolve the relative image
+	data, err := DefaultParser{}.GetMediaData(resp)
+	if err != nil {
+		t.Fatalf("GetMediaData: %v", err)
+	}
+	if data.URL != "" || data.RequestedURL != "" {
+		t.Errorf("URL = %q and RequestedURL = %q, want both empty", data.URL, data.RequestedURL)
+	}
+	if want := []string{"/img/a.jpg", "https://cdn.example.net/b.jpg"}; !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+
+	base, err := url.Parse(testPageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = htmlResponse(t, testPageURL, page)
+	resp.Request = nil
+	data, err = DefaultParser{BaseURL: base}.GetMediaData(resp)
+	if err != nil {
+		t.Fatalf("GetMediaData: %v", err)
+	}
+	if data.URL != testPageURL || data.ImageURLs[0] != "https://example.com/img/a.jpg" {
+		t.Errorf("with BaseURL got URL %q and ImageURLs %q, want them taken from it", data.URL, data.ImageURLs)
+	}
+
+	// The other parsers cope too
+	resp = htmlResponse(t, testPageURL, `<a href="/next">next</a>`)
+	resp.Request = nil
+	if _, err := (LinkParser{}).GetMediaData(resp); err != nil {
+		t.Errorf("LinkParser.GetMediaData: %v", err)
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct{ head, want string }{
+		{`<link rel="canonical" href="/articles/story">`, "https://example.com/articles/story"},
+		{`<link rel="Canonical" href="https://www.example.com/story?id=1">`, "https://www.example.com/story?id=1"},
+		{`<link rel="alternate canonical" href="story">`, "https://example.com/articles/story"},
+		{`<link rel="amphtml" href="/amp/story">`, ""},
+		{`<link rel="canonical" href="">`, ""},
+	}
+	for _, tt := range tests {
+		data := parsePage(t, DefaultParser{}, "<html><head>"+tt.head+"</head><body></body></html>")
+		if data.CanonicalURL != tt.want {
+			t.Errorf("%s: CanonicalURL = %q, want %q", tt.head, data.CanonicalURL, tt.want)
+		}
+	}
+}
+
+func TestBaseHref(t *testing.T) {
+	tests := []struct {
+		head string
+		want []string
+	}{
+		{`<base href="https://cdn.example.com/assets/">`, []string{"https://cdn.example.com/assets/a.png", "https://cdn.example.com/b.png"}},
+		// A relative base is itself resolved against the page URL
+		{`<base href="/static/">`, []string{"https://example.com/static/a.png", "https://example.com/b.png"}},
+		{`<base target="_blank">`, []string{"https://example.com/articles/a.png", "https://example.com/b.png"}},
+		{``, []string{"https://example.com/articles/a.png", "https://example.com/b.png"}},
+	}
+	for _, tt := range tests {
+		data := parsePage(t, DefaultParser{}, "<html><head>"+tt.head+`</head><body>
+<img src="a.png">
+<img src="/b.png">
+</body></html>`)
+		if !slices.Equal(data.ImageURLs, tt.want) {
+			t.Errorf("%s: ImageURLs = %q, want %q", tt.head, data.ImageURLs, tt.want)
+		}
+	}
+}
+
+func TestPageTitle(t *testing.T) {
+	tests := []struct{ page, want string }{
+		{"<html><head><title>  Match\n\treport:   Leeds   1-0  </title></head><body></body></html>", "Match report: Leeds 1-0"},
+		{"<html><head><title>Fish &amp; Chips</title></head><body></body></html>", "Fish & Chips"},
+		{"<html><head></head><body></body></html>", ""},
+		{"<html><head><title></title></head><body></body></html>", ""},
+		// An inline SVG's title isn't the page's
+		{`<html><head></head><body><svg><title>Logo</title></svg></body></html>`, ""},
+		{`<html><head><title>Home</title></head><body><svg><title>Logo</title></svg></body></html>`, "Home"},
+	}
+	for _, tt := range tests {
+		if got := parsePage(t, DefaultParser{}, tt.page).Title; got != tt.want {
+			t.Errorf("%q: Title = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestGetMediaDataFromReader(t *testing.T) {
+	page := `<html><head><title>Saved page</title>
+<meta name="description" content="Kept for later">
+</head><body>
+<img src="photo.jpg" alt="Photo" width="300">
+<img src="/logo.png">
+</body></html>`
+
+	data, err := DefaultParser{}.GetMediaDataFromReader(strings.NewReader(page), testPageURL)
+	if err != nil {
+		t.Fatalf("GetMediaDataFromReader: %v", err)
+	}
+	want := []string{"https://example.com/articles/photo.jpg", "https://example.com/logo.png"}
+	if !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+	if data.URL != testPageURL || data.Title != "Saved page" || data.MetaDescription != "Kept for later" || data.StatusCode != 0 {
+		t.Errorf("GetMediaDataFromReader = %+v", data)
+	}
+
+	// Parsing a response gives the same page details
+	fromResponse := parsePage(t, DefaultParser{}, page)
+	if !slices.Equal(fromResponse.ImageURLs, data.ImageURLs) || !slices.Equal(fromResponse.Images, data.Images) || fromResponse.Title != data.Title {
+		t.Errorf("GetMediaData = %+v, want the same images and title as %+v", fromResponse, data)
+	}
+
+	// Without a page URL links resolve against BaseURL
+	base, _ := url.Parse("https://cdn.example.com/saved/")
+	data, err = DefaultParser{BaseURL: base}.GetMediaDataFromReader(strings.NewReader(page), "")
+	if err != nil {
+		t.Fatalf("GetMediaDataFromReader: %v", err)
+	}
+	if want := "https://cdn.example.com/saved/photo.jpg"; len(data.ImageURLs) == 0 || data.ImageURLs[0] != want {
+		t.Errorf("ImageURLs = %q, want %q first", data.ImageURLs, want)
+	}
+
+	// The charset comes from the document itself
+	latin1 := "<html><head><meta charset=\"iso-8859-1\"><title>Caf\xe9</title></head><body></body></html>"
+	data, err = DefaultParser{}.GetMediaDataFromReader(strings.NewReader(latin1), testPageURL)
+	if err != nil || data.Title != "Café" {
+		t.Errorf("latin-1 page Title = %q, %v, want %q", data.Title, err, "Café")
+	}
+
+	if _, err := (DefaultParser{}).GetMediaDataFromReader(strings.NewReader(page), "http://[::1"); err == nil {
+		t.Error("GetMediaDataFromReader accepted an invalid page URL")
+	}
+}
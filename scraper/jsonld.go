@@ -0,0 +1,86 @@
+package scraper
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// extractStructuredImages returns the image URLs given by the "image"
+// properties of the document's JSON-LD blocks, such as a schema.org
+// NewsArticle's, resolved against base. Blocks that aren't valid JSON are
+// skipped and logged to logger.
+func extractStructuredImages(doc *goquery.Document, base *url.URL, logger *slog.Logger) []string {
+	images := []string{}
+	doc.Find("script[type]").Each(func(i int, s *goquery.Selection) {
+		scriptType, _ := s.Attr("type")
+		if mediaType, _, err := mime.ParseMediaType(scriptType); err != nil || mediaType != "application/ld+json" {
+			return
+		}
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			logger.Debug("Skipping malformed JSON-LD", "url", urlString(base), "err", err)
+			return
+		}
+		for _, ref := range structuredImages(data) {
+			images = append(images, resolveURL(base, ref))
+		}
+	})
+	return images
+}
+
+// structuredImages walks a decoded JSON-LD value, including nested nodes
+// and @graph lists, and returns the URLs of every "image" property found
+func structuredImages(data any) []string {
+	var images []string
+	switch v := data.(type) {
+	case []any:
+		for _, item := range v {
+			images = append(images, structuredImages(item)...)
+		}
+	case map[string]any:
+		// Walk the properties in a fixed order so the URLs are too
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if key == "image" {
+				images = append(images, imageRefs(v[key])...)
+			} else {
+				images = append(images, structuredImages(v[key])...)
+			}
+		}
+	}
+	return images
+}
+
+// imageRefs returns the URLs of an "image" value, which may be a URL, an
+// ImageObject with a url or contentUrl, or a list of either
+func imageRefs(value any) []string {
+	var refs []string
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) != "" {
+			refs = append(refs, v)
+		}
+	case []any:
+		for _, item := range v {
+			refs = append(refs, imageRefs(item)...)
+		}
+	case map[string]any:
+		for _, key := range []string{"url", "contentUrl"} {
+			if ref, ok := v[key].(string); ok && strings.TrimSpace(ref) != "" {
+				refs = append(refs, ref)
+				break
+			}
+		}
+	}
+	return refs
+}
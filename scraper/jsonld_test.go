@@ -0,0 +1,45 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStructuredImages(t *testing.T) {
+	data := parsePage(t, DefaultParser{}, `<html><head>
+<script type="application/ld+json">
+{
+  "@context": "https://schema.org",
+  "@type": "NewsArticle",
+  "headline": "Leeds win at home",
+  "image": [
+    "https://example.com/photos/1x1/photo.jpg",
+    {"@type": "ImageObject", "url": "/photos/4x3/photo.jpg", "width": 800},
+    {"@type": "ImageObject", "contentUrl": "photos/16x9/photo.jpg"}
+  ],
+  "author": {"@type": "Person", "name": "A. Writer", "image": "/authors/writer.png"},
+  "publisher": {"@type": "Organization", "logo": {"@type": "ImageObject", "url": "/logo.png"}}
+}
+</script>
+<script type="application/ld+json; charset=utf-8">
+{"@graph": [{"@type": "WebPage", "image": {"url": "https://cdn.example.com/page.jpg"}}]}
+</script>
+<script type="application/ld+json">{ not json </script>
+<script type="text/javascript">var data = {"image": "/not-structured.jpg"};</script>
+</head><body></body></html>`)
+
+	want := []string{
+		"https://example.com/authors/writer.png",
+		"https://example.com/photos/1x1/photo.jpg",
+		"https://example.com/photos/4x3/photo.jpg",
+		"https://example.com/articles/photos/16x9/photo.jpg",
+		"https://cdn.example.com/page.jpg",
+	}
+	if !slices.Equal(data.StructuredImages, want) {
+		t.Errorf("StructuredImages = %q, want %q", data.StructuredImages, want)
+	}
+
+	if data := parsePage(t, DefaultParser{}, "<html><body></body></html>"); data.StructuredImages == nil || len(data.StructuredImages) != 0 {
+		t.Errorf("StructuredImages of a page without JSON-LD = %#v, want empty", data.StructuredImages)
+	}
+}
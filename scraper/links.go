@@ -0,0 +1,60 @@
+package scraper
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// LinkParser extracts a page's <a href> links in addition to everything
+// DefaultParser extracts, so pages can be discovered without a sitemap
+type LinkParser struct {
+	DefaultParser
+	// AllHosts keeps links to other hosts; by default only links to the
+	// page's own host are kept
+	AllHosts bool
+}
+
+// GetMediaData extracts the images and links from the response
+func (p LinkParser) GetMediaData(resp *http.Response) (MediaData, error) {
+	data, doc, err := p.DefaultParser.responseMediaData(resp)
+	if err != nil || doc == nil {
+		return data, err
+	}
+	data.Links = extractLinks(doc, responseURL(resp, p.BaseURL), !p.AllHosts)
+	return data, nil
+}
+
+// extractLinks returns the absolute http(s) URLs of the document's anchors,
+// resolved against its <base href> or else pageURL, without fragments and
+// without repeats. When sameHost is set only links to the host of pageURL
+// are kept.
+func extractLinks(doc *goquery.Document, pageURL *url.URL, sameHost bool) []string {
+	// Without a page URL only absolute links can be kept
+	if pageURL == nil {
+		pageURL = &url.URL{}
+	}
+	base := documentBase(doc, pageURL)
+	links := []string{}
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		href = strings.TrimSpace(href)
+		if href == "" || strings.HasPrefix(href, "#") {
+			return
+		}
+
+		link, err := base.Parse(href)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+			return
+		}
+		if sameHost && !strings.EqualFold(link.Hostname(), pageURL.Hostname()) {
+			return
+		}
+		link.Fragment = ""
+		link.RawFragment = ""
+		links = append(links, link.String())
+	})
+	return dedupeStrings(links)
+}
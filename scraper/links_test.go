@@ -0,0 +1,42 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+)
+
+// linksPage links to pages on its own host and elsewhere, along with links
+// that can't be crawled
+const linksPage = `<html><body>
+<img src="/img/a.jpg">
+<a href="/news/1">News</a>
+<a href="sports/2#scores">Sports</a>
+<a href="/news/1#top">News again</a>
+<a href="HTTPS://EXAMPLE.COM/about">About</a>
+<a href="https://other.example.net/page">Elsewhere</a>
+<a href="#top">Top</a>
+<a href="mailto:editor@example.com">Mail</a>
+<a href="javascript:void(0)">Script</a>
+<a href="">Empty</a>
+</body></html>`
+
+func TestLinkParser(t *testing.T) {
+	data := parsePage(t, LinkParser{}, linksPage)
+	want := []string{
+		"https://example.com/news/1",
+		"https://example.com/articles/sports/2",
+		// The host is compared without regard to case
+		"https://EXAMPLE.COM/about",
+	}
+	if !slices.Equal(data.Links, want) {
+		t.Errorf("Links = %q, want %q", data.Links, want)
+	}
+	if !slices.Equal(data.ImageURLs, []string{"https://example.com/img/a.jpg"}) {
+		t.Errorf("ImageURLs = %q, want the page's image", data.ImageURLs)
+	}
+
+	data = parsePage(t, LinkParser{AllHosts: true}, linksPage)
+	if want := append(want, "https://other.example.net/page"); !slices.Equal(data.Links, want) {
+		t.Errorf("with AllHosts Links = %q, want %q", data.Links, want)
+	}
+}
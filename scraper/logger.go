@@ -0,0 +1,10 @@
+package scraper
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Logger receives the progress and error messages of scrapers without a
+// Logger of their own
+var Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
@@ -0,0 +1,40 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mediaSources returns the URLs of the document's tag elements (video or
+// audio), taken from their src attribute and from their <source> children
+func mediaSources(doc *goquery.Document, tag string, base *url.URL) []string {
+	urls := []string{}
+	doc.Find(tag + "[src], " + tag + " source[src]").Each(func(i int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		if strings.TrimSpace(src) != "" {
+			urls = append(urls, resolveURL(base, src))
+		}
+	})
+	return urls
+}
+
+// ParseMediaTypes reads a media type list such as "image,video" into the
+// DefaultParser switches for the non-image types. Images are always
+// extracted.
+func ParseMediaTypes(list string) (video, audio bool, err error) {
+	for _, mediaType := range strings.Split(list, ",") {
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "", "image":
+		case "video":
+			video = true
+		case "audio":
+			audio = true
+		default:
+			return false, false, fmt.Errorf("unknown media type %q", mediaType)
+		}
+	}
+	return video, audio, nil
+}
@@ -0,0 +1,63 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+)
+
+// mediaPage has a video with a poster and sources, an audio clip and an image
+const mediaPage = `<html><body>
+<img src="/img/a.jpg">
+<video src="/media/intro.mp4" poster="/img/poster.jpg"></video>
+<video controls>
+  <source src="/media/clip.webm" type="video/webm">
+  <source src="/media/clip.mp4" type="video/mp4">
+  <source src="/media/intro.mp4">
+</video>
+<audio><source src="podcast.mp3" type="audio/mpeg"></audio>
+</body></html>`
+
+func TestMediaSources(t *testing.T) {
+	data := parsePage(t, DefaultParser{Video: true, Audio: true}, mediaPage)
+	wantVideo := []string{
+		"https://example.com/media/intro.mp4",
+		"https://example.com/media/clip.webm",
+		"https://example.com/media/clip.mp4",
+	}
+	if !slices.Equal(data.VideoURLs, wantVideo) {
+		t.Errorf("VideoURLs = %q, want %q", data.VideoURLs, wantVideo)
+	}
+	if want := []string{"https://example.com/articles/podcast.mp3"}; !slices.Equal(data.AudioURLs, want) {
+		t.Errorf("AudioURLs = %q, want %q", data.AudioURLs, want)
+	}
+	// Media sources stay out of the images
+	if want := []string{"https://example.com/img/a.jpg"}; !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+
+	data = parsePage(t, DefaultParser{}, mediaPage)
+	if len(data.VideoURLs) != 0 || len(data.AudioURLs) != 0 {
+		t.Errorf("without Video and Audio got %q and %q, want neither", data.VideoURLs, data.AudioURLs)
+	}
+}
+
+func TestParseMediaTypes(t *testing.T) {
+	tests := []struct {
+		list         string
+		video, audio bool
+	}{
+		{"image", false, false},
+		{"", false, false},
+		{"image, Video", true, false},
+		{"audio,video", true, true},
+	}
+	for _, tt := range tests {
+		video, audio, err := ParseMediaTypes(tt.list)
+		if err != nil || video != tt.video || audio != tt.audio {
+			t.Errorf("ParseMediaTypes(%q) = %v, %v, %v, want %v, %v", tt.list, video, audio, err, tt.video, tt.audio)
+		}
+	}
+	if _, _, err := ParseMediaTypes("image,pdf"); err == nil {
+		t.Error("ParseMediaTypes of an unknown type returned no error")
+	}
+}
@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultTrackingParams lists the usual tracking query parameters, in the
+// form ParseParamList reads
+const DefaultTrackingParams = "utm_*,fbclid,gclid,dclid,msclkid,mc_cid,mc_eid,_ga,igshid"
+
+// URLNormalizer rewrites equivalent image URLs to one form so that they are
+// recognised as duplicates. It lowercases the host, drops the fragment and
+// removes the listed query parameters.
+type URLNormalizer struct {
+	// StripParams are query parameter names to remove. A name ending in "*"
+	// matches every parameter starting with the rest of it, e.g. "utm_*".
+	StripParams []string
+}
+
+// ParseParamList splits a comma separated parameter list, dropping blanks
+func ParseParamList(list string) []string {
+	var params []string
+	for _, param := range strings.Split(list, ",") {
+		if param = strings.TrimSpace(param); param != "" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// Normalize returns the normalized form of rawURL. URLs that aren't http or
+// https, such as data: images, are returned unchanged.
+func (n *URLNormalizer) Normalize(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return rawURL
+	}
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	// Filter the raw query piece by piece so the remaining parameters keep
+	// their order and encoding
+	if parsed.RawQuery != "" {
+		var kept []string
+		for _, pair := range strings.Split(parsed.RawQuery, "&") {
+			name, _, _ := strings.Cut(pair, "=")
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+			if pair != "" && !n.strips(name) {
+				kept = append(kept, pair)
+			}
+		}
+		parsed.RawQuery = strings.Join(kept, "&")
+	}
+	parsed.ForceQuery = false
+	return parsed.String()
+}
+
+// strips reports whether the query parameter called name is removed
+func (n *URLNormalizer) strips(name string) bool {
+	for _, param := range n.StripParams {
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == param {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAll normalizes each of urls in place
+func (n *URLNormalizer) normalizeAll(urls []string) {
+	for i, rawURL := range urls {
+		urls[i] = n.Normalize(rawURL)
+	}
+}
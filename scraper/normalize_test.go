@@ -0,0 +1,39 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	n := &URLNormalizer{StripParams: ParseParamList(DefaultTrackingParams + ", ref")}
+	tests := []struct{ in, want string }{
+		{"http://x.com/img.jpg?utm_source=a", "http://x.com/img.jpg"},
+		{"http://X.COM/Img.jpg#top", "http://x.com/Img.jpg"},
+		{"https://x.com/img.jpg?w=100&utm_medium=b&h=50", "https://x.com/img.jpg?w=100&h=50"},
+		{"https://x.com/img.jpg?fbclid=1&ref=home", "https://x.com/img.jpg"},
+		{"https://x.com/img.jpg?name=a%20b&gclid=2", "https://x.com/img.jpg?name=a%20b"},
+		{"https://x.com/img.jpg?", "https://x.com/img.jpg"},
+		{"https://x.com/img.jpg?reference=1", "https://x.com/img.jpg?reference=1"},
+		{"data:image/png;base64,AAAA", "data:image/png;base64,AAAA"},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeBeforeDedupe(t *testing.T) {
+	parser := DefaultParser{Normalizer: &URLNormalizer{StripParams: []string{"utm_*"}}}
+	data := parsePage(t, parser, `<html><body>
+<img src="http://x.com/img.jpg?utm=a&utm_source=feed">
+<img src="http://X.com/img.jpg?utm=a#hero">
+<img src="http://x.com/img.jpg?utm=b">
+</body></html>`)
+
+	want := []string{"http://x.com/img.jpg?utm=a", "http://x.com/img.jpg?utm=b"}
+	if !slices.Equal(data.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", data.ImageURLs, want)
+	}
+}
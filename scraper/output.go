@@ -0,0 +1,332 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OutputWriter writes a set of scrape results in some output format
+type OutputWriter interface {
+	Write(results []MediaData) error
+}
+
+// StreamWriter is an OutputWriter that can also write results one at a time
+// as they arrive. A writer is used either for a single Write or for a series
+// of WriteResult calls followed by Close, which finishes the output.
+type StreamWriter interface {
+	OutputWriter
+	WriteResult(res MediaData) error
+	Close() error
+}
+
+// writeAll implements Write for a StreamWriter
+func writeAll(w StreamWriter, results []MediaData) error {
+	for _, res := range results {
+		if err := w.WriteResult(res); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// SortResults orders results by page URL, then by the URL that was
+// requested, so the same pages are always written in the same order
+func SortResults(results []MediaData) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].URL != results[j].URL {
+			return results[i].URL < results[j].URL
+		}
+		return results[i].RequestedURL < results[j].RequestedURL
+	})
+}
+
+// TextWriter writes the results in the plain text report format
+type TextWriter struct {
+	W io.Writer
+	// Fields selects which of url, status_code, title, meta_description
+	// and image_urls are written; empty means all of them
+	Fields []string
+}
+
+// Write writes one block per page listing its images
+func (t *TextWriter) Write(results []MediaData) error {
+	return writeAll(t, results)
+}
+
+// WriteResult writes the block for a single page
+func (t *TextWriter) WriteResult(res MediaData) error {
+	output := ""
+	if wantsField(t.Fields, "url") {
+		output += fmt.Sprintf("URL: %s\n", res.URL)
+	}
+	if wantsField(t.Fields, "status_code") {
+		output += fmt.Sprintf("StatusCode: %d\n", res.StatusCode)
+	}
+	if wantsField(t.Fields, "title") {
+		output += fmt.Sprintf("Title: %s\n", res.Title)
+	}
+	if wantsField(t.Fields, "meta_description") {
+		output += fmt.Sprintf("Meta Description: %s\n", res.MetaDescription)
+	}
+	if wantsField(t.Fields, "image_urls") {
+		output += "Images:\n"
+		details := make(map[string]Image, len(res.Images))
+		for _, img := range res.Images {
+			details[img.URL] = img
+		}
+		for _, imgURL := range res.ImageURLs {
+			status, checked := res.ImageStatuses[imgURL]
+			output += fmt.Sprintf("- %s%s\n", imgURL, imageDetails(details[imgURL], status, checked))
+		}
+	}
+	output += "\n"
+	_, err := io.WriteString(t.W, output)
+	if err != nil {
+		return fmt.Errorf("writing result for URL %s: %w", res.URL, err)
+	}
+	return nil
+}
+
+// Close has nothing to finish for the text report
+func (t *TextWriter) Close() error {
+	return nil
+}
+
+// imageDetails formats the alt text and declared size of an image, and its
+// status when checked, for the text report, e.g. ` (alt: "A dog", 300x200)`
+func imageDetails(img Image, status int, checked bool) string {
+	var parts []string
+	if img.Alt != "" {
+		parts = append(parts, fmt.Sprintf("alt: %q", img.Alt))
+	}
+	if img.Width > 0 || img.Height > 0 {
+		parts = append(parts, fmt.Sprintf("%dx%d", img.Width, img.Height))
+	}
+	if checked {
+		if status == 0 {
+			parts = append(parts, "no response")
+		} else {
+			parts = append(parts, fmt.Sprintf("status: %d", status))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// JSONWriter writes the results as an indented JSON array
+type JSONWriter struct {
+	W io.Writer
+	// Fields selects the MediaData fields written, by JSON name; empty
+	// means all of them
+	Fields []string
+	count  int
+}
+
+// Write encodes all the results as a single array
+func (j *JSONWriter) Write(results []MediaData) error {
+	return writeAll(j, results)
+}
+
+// WriteResult adds one page to the array, opening it first if needed
+func (j *JSONWriter) WriteResult(res MediaData) error {
+	// Indent each element as if the whole array were encoded at once
+	object, err := marshalFields(res, j.Fields)
+	if err != nil {
+		return fmt.Errorf("writing result for URL %s: %w", res.URL, err)
+	}
+	var element bytes.Buffer
+	if err := json.Indent(&element, object, "  ", "  "); err != nil {
+		return fmt.Errorf("writing result for URL %s: %w", res.URL, err)
+	}
+	separator := ",\n  "
+	if j.count == 0 {
+		separator = "[\n  "
+	}
+	if _, err := io.WriteString(j.W, separator); err != nil {
+		return fmt.Errorf("writing result for URL %s: %w", res.URL, err)
+	}
+	if _, err := element.WriteTo(j.W); err != nil {
+		return fmt.Errorf("writing result for URL %s: %w", res.URL, err)
+	}
+	j.count++
+	return nil
+}
+
+// Close ends the array
+func (j *JSONWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.W, end)
+	return err
+}
+
+// NDJSONWriter writes each result as a JSON object on its own line, so the
+// output can be processed one page at a time
+type NDJSONWriter struct {
+	W io.Writer
+	// Fields selects the MediaData fields written, by JSON name; empty
+	// means all of them
+	Fields []string
+}
+
+// Write writes one line per page
+func (n *NDJSONWriter) Write(results []MediaData) error {
+	return writeAll(n, results)
+}
+
+// WriteResult writes the line for a single page
+func (n *NDJSONWriter) WriteResult(res MediaData) error {
+	line, err := marshalFields(res, n.Fields)
+	if err == nil {
+		_, err = n.W.Write(append(line, '\n'))
+	}
+	if err != nil {
+		return fmt.Errorf("writing result for URL %s: %w", res.URL, err)
+	}
+	return nil
+}
+
+// Close has nothing to finish for NDJSON
+func (n *NDJSONWriter) Close() error {
+	return nil
+}
+
+// CSVWriter writes one row per image with the page URL, status code and meta
+// description repeated on each row. Pages without images get a single row
+// with an empty image_url.
+type CSVWriter struct {
+	W io.Writer
+	// Fields selects which of url, status_code, meta_description and
+	// image_urls get a column; empty means all of them. Without image_urls
+	// each page gets a single row.
+	Fields []string
+	writer *csv.Writer
+}
+
+// csvColumns pairs each MediaData field the CSV output can hold with the
+// header of its column
+var csvColumns = []struct{ field, header string }{
+	{"url", "page_url"},
+	{"status_code", "status_code"},
+	{"meta_description", "meta_description"},
+	{"image_urls", "image_url"},
+}
+
+// columns returns the selected fields in column order
+func (c *CSVWriter) columns() []string {
+	var fields []string
+	for _, column := range csvColumns {
+		if wantsField(c.Fields, column.field) {
+			fields = append(fields, column.field)
+		}
+	}
+	return fields
+}
+
+// Write writes the header row followed by the rows of every page
+func (c *CSVWriter) Write(results []MediaData) error {
+	return writeAll(c, results)
+}
+
+// start writes the header row the first time a row is needed
+func (c *CSVWriter) start() error {
+	if c.writer != nil {
+		return nil
+	}
+	c.writer = csv.NewWriter(c.W)
+	var header []string
+	for _, column := range csvColumns {
+		if wantsField(c.Fields, column.field) {
+			header = append(header, column.header)
+		}
+	}
+	return c.writer.Write(header)
+}
+
+// WriteResult writes the rows of a single page
+func (c *CSVWriter) WriteResult(res MediaData) error {
+	if err := c.start(); err != nil {
+		return err
+	}
+
+	values := map[string]string{
+		"url":              res.URL,
+		"status_code":      strconv.Itoa(res.StatusCode),
+		"meta_description": res.MetaDescription,
+	}
+	imageURLs := res.ImageURLs
+	if len(imageURLs) == 0 || !wantsField(c.Fields, "image_urls") {
+		imageURLs = []string{""}
+	}
+	columns := c.columns()
+	for _, imgURL := range imageURLs {
+		values["image_urls"] = imgURL
+		var row []string
+		for _, field := range columns {
+			row = append(row, values[field])
+		}
+		if err := c.writer.Write(row); err != nil {
+			return fmt.Errorf("writing result for URL %s: %w", res.URL, err)
+		}
+	}
+	return nil
+}
+
+// Close flushes the buffered rows, writing the header even when there were
+// no pages
+func (c *CSVWriter) Close() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// OutputFormat pairs a results writer with the file extension it produces.
+// The writer only writes the given fields, or all of them when there are
+// none.
+type OutputFormat struct {
+	NewWriter func(w io.Writer, fields []string) StreamWriter
+	Extension string
+	// Fields are the fields the format can select; nil means any MediaData
+	// field
+	Fields []string
+}
+
+// textFields and csvFields are the fields of the text and CSV reports
+var (
+	textFields = []string{"url", "status_code", "title", "meta_description", "image_urls"}
+	csvFields  = []string{"url", "status_code", "meta_description", "image_urls"}
+)
+
+// OutputFormats maps each output format name to its writer
+var OutputFormats = map[string]OutputFormat{
+	"text":   {func(w io.Writer, fields []string) StreamWriter { return &TextWriter{W: w, Fields: fields} }, "txt", textFields},
+	"json":   {func(w io.Writer, fields []string) StreamWriter { return &JSONWriter{W: w, Fields: fields} }, "json", nil},
+	"csv":    {func(w io.Writer, fields []string) StreamWriter { return &CSVWriter{W: w, Fields: fields} }, "csv", csvFields},
+	"ndjson": {func(w io.Writer, fields []string) StreamWriter { return &NDJSONWriter{W: w, Fields: fields} }, "ndjson", nil},
+}
+
+// CheckFields reports an error when the format can't select one of fields
+func (f OutputFormat) CheckFields(fields []string) error {
+	if f.Fields == nil {
+		return nil
+	}
+	for _, field := range fields {
+		if !slices.Contains(f.Fields, field) {
+			return fmt.Errorf("field %q isn't available in this format, which has %s", field, strings.Join(f.Fields, ", "))
+		}
+	}
+	return nil
+}
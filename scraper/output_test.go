@@ -0,0 +1,203 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleResults returns results filling in most MediaData fields
+func sampleResults() []MediaData {
+	return []MediaData{
+		{
+			URL:             "https://example.com/a",
+			RequestedURL:    "http://example.com/a",
+			FinalURL:        "https://example.com/a",
+			ImageURLs:       []string{"https://example.com/1.jpg", "https://example.com/2.png"},
+			Images:          []Image{{URL: "https://example.com/1.jpg", Alt: "A dog", Width: 300, Height: 200}},
+			SocialImages:    []string{"https://example.com/2.png"},
+			StatusCode:      200,
+			Title:           "Page A",
+			MetaDescription: `Quotes "and", commas`,
+			Icons:           []Icon{{URL: "https://example.com/favicon.ico", Rel: "icon"}},
+			ImageStatuses:   map[string]int{"https://example.com/1.jpg": 200},
+			ImageInfo:       map[string]ImageInfo{"https://example.com/2.png": {Format: "png", Width: 16, Height: 16}},
+			FetchDuration:   12 * time.Millisecond,
+			ContentLength:   -1,
+			BodySize:        512,
+		},
+		{
+			URL:        "https://example.com/b",
+			ImageURLs:  []string{},
+			StatusCode: 200,
+			Skipped:    true,
+		},
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	results := sampleResults()
+	var buf bytes.Buffer
+	if err := (&JSONWriter{W: &buf}).Write(results); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Writing one page at a time gives the same bytes as indenting the
+	// whole array at once
+	want, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want)+"\n" {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+
+	var decoded []MediaData
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output isn't a JSON array: %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(decoded, results) {
+		t.Errorf("decoded results = %+v, want %+v", decoded, results)
+	}
+}
+
+func TestJSONWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&JSONWriter{W: &buf}).Write(nil); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("output = %q, want an empty array", got)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&CSVWriter{W: &buf}).Write(sampleResults()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := `page_url,status_code,meta_description,image_url
+https://example.com/a,200,"Quotes ""and"", commas",https://example.com/1.jpg
+https://example.com/a,200,"Quotes ""and"", commas",https://example.com/2.png
+https://example.com/b,200,,
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCSVWriterFields(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&CSVWriter{W: &buf, Fields: []string{"url", "status_code"}}).Write(sampleResults()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	// Without image_urls each page is one row
+	want := "page_url,status_code\nhttps://example.com/a,200\nhttps://example.com/b,200\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestCSVWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&CSVWriter{W: &buf}).Write(nil); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got, want := buf.String(), "page_url,status_code,meta_description,image_url\n"; got != want {
+		t.Errorf("output = %q, want just the header %q", got, want)
+	}
+}
+
+func TestTextWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&TextWriter{W: &buf}).Write(sampleResults()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := `URL: https://example.com/a
+StatusCode: 200
+Title: Page A
+Meta Description: Quotes "and", commas
+Images:
+- https://example.com/1.jpg (alt: "A dog", 300x200, status: 200)
+- https://example.com/2.png
+
+URL: https://example.com/b
+StatusCode: 200
+Title: 
+Meta Description: 
+Images:
+
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	for name, format := range OutputFormats {
+		// Writing all the results at once and one at a time give the same
+		// output in every format
+		var whole, streamed bytes.Buffer
+		var w OutputWriter = format.NewWriter(&whole, nil)
+		if err := w.Write(sampleResults()); err != nil {
+			t.Errorf("%s: Write: %v", name, err)
+		}
+		stream := format.NewWriter(&streamed, nil)
+		for _, res := range sampleResults() {
+			if err := stream.WriteResult(res); err != nil {
+				t.Errorf("%s: WriteResult: %v", name, err)
+			}
+		}
+		if err := stream.Close(); err != nil {
+			t.Errorf("%s: Close: %v", name, err)
+		}
+		if whole.String() != streamed.String() {
+			t.Errorf("%s: Write gave\n%s\nwhile WriteResult gave\n%s", name, whole.String(), streamed.String())
+		}
+		if whole.Len() == 0 {
+			t.Errorf("%s: wrote nothing", name)
+		}
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestOutputWriteError(t *testing.T) {
+	for name, format := range OutputFormats {
+		if err := format.NewWriter(failingWriter{}, nil).Write(sampleResults()); err == nil {
+			t.Errorf("%s: Write to a failing writer returned no error", name)
+		}
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	results := sampleResults()
+	var buf bytes.Buffer
+	if err := (&NDJSONWriter{W: &buf}).Write(results); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(results) {
+		t.Fatalf("got %d lines, want one per page:\n%s", len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var decoded MediaData
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Errorf("line %d isn't a JSON object: %v", i+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, results[i]) {
+			t.Errorf("line %d = %+v, want %+v", i+1, decoded, results[i])
+		}
+	}
+}
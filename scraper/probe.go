@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif" // register the decoders ProbeImages can read
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// imageProbeBytes is how much of each image ProbeImages reads. The size is
+// near the start for PNG and GIF but can follow large metadata in JPEGs.
+const imageProbeBytes = 64 << 10
+
+// ImageInfo is what the header of an image file says about it
+type ImageInfo struct {
+	Format string `json:"format"` // e.g. "png", "jpeg" or "gif"
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ProbeImages reads the start of every unique image in imageURLs and returns
+// the format and dimensions decoded from it. Only the first imageProbeBytes
+// of each are requested. Images that can't be fetched or decoded, including
+// formats other than PNG, JPEG and GIF, are left out.
+func (s *Scraper) ProbeImages(ctx context.Context, imageURLs []string) map[string]ImageInfo {
+	infos := make(map[string]ImageInfo)
+	var mu sync.Mutex
+	s.eachImage(imageURLs, func(imgURL string) {
+		info, err := s.probeImage(ctx, imgURL)
+		if err != nil {
+			s.log().Debug("Image header not decoded", "url", imgURL, "err", err)
+			return
+		}
+		mu.Lock()
+		infos[imgURL] = info
+		mu.Unlock()
+	})
+	return infos
+}
+
+// probeImage decodes the header of the image at imgURL
+func (s *Scraper) probeImage(ctx context.Context, imgURL string) (ImageInfo, error) {
+	// Servers that ignore the Range header send the whole file, of which
+	// only the start is read
+	header := http.Header{"Range": {fmt.Sprintf("bytes=0-%d", imageProbeBytes-1)}}
+	resp, err := s.sendRequest(ctx, http.MethodGet, imgURL, header)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return ImageInfo{}, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	start, err := io.ReadAll(io.LimitReader(resp.Body, imageProbeBytes))
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(start))
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	return ImageInfo{Format: format, Width: config.Width, Height: config.Height}, nil
+}
+
+// SetImageInfo fills in res.ImageInfo for the page's images from the
+// results of ProbeImages
+func SetImageInfo(res *MediaData, infos map[string]ImageInfo) {
+	res.ImageInfo = make(map[string]ImageInfo)
+	for _, imgURL := range res.ImageURLs {
+		if info, ok := infos[imgURL]; ok {
+			res.ImageInfo[imgURL] = info
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProbeImages(t *testing.T) {
+	var gifImage bytes.Buffer
+	if err := gif.Encode(&gifImage, image.NewPaletted(image.Rect(0, 0, 7, 5), color.Palette{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"/photo.png": pngBytes(t),
+		"/anim.gif":  gifImage.Bytes(),
+		"/fake.png":  []byte("not an image at all"),
+	}
+	var mu sync.Mutex
+	ranges := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges[r.URL.Path] = r.Header.Get("Range")
+		mu.Unlock()
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(body))
+	}))
+	defer server.Close()
+
+	photo, anim := server.URL+"/photo.png", server.URL+"/anim.gif"
+	infos := (&Scraper{Concurrency: 2}).ProbeImages(context.Background(), []string{
+		photo, anim, photo, server.URL + "/fake.png", server.URL + "/missing.png",
+	})
+	want := map[string]ImageInfo{
+		photo: {Format: "png", Width: 4, Height: 3},
+		anim:  {Format: "gif", Width: 7, Height: 5},
+	}
+	if fmt.Sprint(infos) != fmt.Sprint(want) {
+		t.Errorf("ProbeImages = %v, want %v", infos, want)
+	}
+	if got, want := ranges["/photo.png"], fmt.Sprintf("bytes=0-%d", imageProbeBytes-1); got != want {
+		t.Errorf("Range header = %q, want %q", got, want)
+	}
+
+	res := MediaData{ImageURLs: []string{photo, server.URL + "/fake.png"}}
+	SetImageInfo(&res, infos)
+	if fmt.Sprint(res.ImageInfo) != fmt.Sprint(map[string]ImageInfo{photo: want[photo]}) {
+		t.Errorf("ImageInfo = %v", res.ImageInfo)
+	}
+}
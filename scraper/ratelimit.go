@@ -0,0 +1,153 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// tokenBucket tracks the request allowance for a single host
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// HostRateLimiter is a token-bucket rate limiter keyed by hostname. Each host
+// gets its own bucket, so requests to different hosts never wait on each other.
+type HostRateLimiter struct {
+	rate  float64 // tokens added per second
+	burst float64 // bucket capacity
+	mu    sync.Mutex
+	hosts map[string]*tokenBucket
+}
+
+// NewHostRateLimiter allows rate requests per second to each host, with
+// bursts of up to burst requests
+func NewHostRateLimiter(rate float64, burst int) *HostRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &HostRateLimiter{
+		rate:  rate,
+		burst: float64(burst),
+		hosts: make(map[string]*tokenBucket),
+	}
+}
+
+// reserve takes a token from host's bucket and returns how long the caller
+// must wait before the token becomes valid. Tokens are reserved even when the
+// bucket is empty, so concurrent callers queue up evenly spaced.
+func (l *HostRateLimiter) reserve(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.hosts[host]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.hosts[host] = bucket
+	}
+
+	// Refill for the time elapsed since the last request
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bucket.tokens / l.rate * float64(time.Second))
+}
+
+// Wait blocks until a request to host is allowed or ctx is cancelled
+func (l *HostRateLimiter) Wait(ctx context.Context, host string) error {
+	wait := l.reserve(host)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitForHost applies the scraper's rate limiter to the host of rawURL
+func (s *Scraper) waitForHost(ctx context.Context, rawURL string) error {
+	if s.RateLimiter == nil {
+		return nil
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return s.RateLimiter.Wait(ctx, parsed.Hostname())
+}
+
+// HostDelays enforces a minimum interval between the starts of consecutive
+// requests to the same host. The zero value is ready to use.
+type HostDelays struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// reserve claims the next slot for host at least interval after the previous
+// one and returns how long the caller must wait for it. Concurrent callers
+// are queued one interval apart.
+func (d *HostDelays) reserve(host string, interval time.Duration) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.next == nil {
+		d.next = make(map[string]time.Time)
+	}
+	now := time.Now()
+	slot := d.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	d.next[host] = slot.Add(interval)
+	return slot.Sub(now)
+}
+
+// Wait blocks until a request to host may start or ctx is cancelled
+func (d *HostDelays) Wait(ctx context.Context, host string, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+	wait := d.reserve(host, interval)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitCrawlDelay waits out the crawl delay for the host of rawURL: the Delay
+// interval when set, otherwise the host's robots.txt Crawl-delay
+func (s *Scraper) waitCrawlDelay(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+	interval := s.Delay
+	if interval == 0 && !s.IgnoreRobots {
+		interval = s.robotsFor(ctx, parsed).CrawlDelay(robotsUserAgent)
+	}
+	return s.hostDelays.Wait(ctx, parsed.Hostname(), interval)
+}
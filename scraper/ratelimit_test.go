@@ -0,0 +1,109 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostRateLimiterReserve(t *testing.T) {
+	l := NewHostRateLimiter(10, 2)
+	var waits []time.Duration
+	for i := 0; i < 4; i++ {
+		waits = append(waits, l.reserve("example.com"))
+	}
+	// The burst goes straight away, then one request every 100ms
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if diff := waits[i] - want; diff < -5*time.Millisecond || diff > 5*time.Millisecond {
+			t.Errorf("request %d waits %v, want about %v", i+1, waits[i], want)
+		}
+	}
+	if wait := l.reserve("other.example.com"); wait != 0 {
+		t.Errorf("first request to another host waits %v, want 0", wait)
+	}
+}
+
+func TestHostRateLimiterWaitCancel(t *testing.T) {
+	l := NewHostRateLimiter(0.1, 1)
+	l.Wait(context.Background(), "example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "example.com"); err != context.DeadlineExceeded {
+		t.Errorf("Wait = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestScrapeRateLimit(t *testing.T) {
+	const rate = 20
+	var mu sync.Mutex
+	var starts []time.Time
+	server := pageServer(t, func(r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			return
+		}
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+	})
+
+	s := &Scraper{Concurrency: 6, RateLimiter: NewHostRateLimiter(rate, 1)}
+	if _, errs := s.Scrape(pageURLs(server, 6)); len(errs) != 0 {
+		t.Fatalf("Scrape errors = %v", errs)
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	// Allow some slack for scheduling, but the requests must be spread out
+	// rather than sent together by the six workers
+	interval := time.Second / rate
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < interval*3/4 {
+			t.Errorf("requests %d and %d were %v apart, want about %v", i, i+1, gap, interval)
+		}
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		delay  time.Duration
+		want   time.Duration
+	}{
+		{"robots.txt", "User-agent: *\nCrawl-delay: 0.05\n", 0, 50 * time.Millisecond},
+		{"Delay", "User-agent: *\nCrawl-delay: 5\n", 40 * time.Millisecond, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var starts []time.Time
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				fmt.Fprint(w, tt.robots)
+				return
+			}
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><body></body></html>")
+		}))
+		defer server.Close()
+
+		s := &Scraper{Concurrency: 4, Delay: tt.delay}
+		if _, errs := s.Scrape(pageURLs(server, 5)); len(errs) != 0 {
+			t.Fatalf("%s: Scrape errors = %v", tt.name, errs)
+		}
+		// The workers run at once, but the requests to the host are spaced
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+		for i := 1; i < len(starts); i++ {
+			if gap := starts[i].Sub(starts[i-1]); gap < tt.want*9/10 {
+				t.Errorf("%s: requests %d and %d were %v apart, want at least %v", tt.name, i, i+1, gap, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAttempts is how many times a request is tried when
+// Scraper.MaxAttempts is zero
+const DefaultMaxAttempts = 3
+
+// DefaultRetryDelay is the wait before the first retry when
+// Scraper.RetryDelay is zero
+var DefaultRetryDelay = 500 * time.Millisecond
+
+// maxAttempts returns how many times a request is tried, applying the default
+func (s *Scraper) maxAttempts() int {
+	if s.MaxAttempts == 0 {
+		return DefaultMaxAttempts
+	}
+	return max(s.MaxAttempts, 1)
+}
+
+// shouldRetry reports whether a request outcome looks transient: a timeout
+// or connection error, a 5xx server error, or 429 Too Many Requests.
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return transientError(err)
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+// transientError reports whether err is a timeout or a failed or dropped
+// connection. Certificate errors, unsupported schemes, hosts that don't
+// exist, redirect loops and cancellation fail the same way every time.
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, errTooManyRedirects) {
+		return false
+	}
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) {
+		return false
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoffDelay returns the exponential backoff for the given attempt with up
+// to 50% random jitter added so retries from many workers don't line up.
+func (s *Scraper) backoffDelay(attempt int) time.Duration {
+	base := s.RetryDelay
+	if base == 0 {
+		base = DefaultRetryDelay
+	}
+	delay := base << (attempt - 1)
+
+	rngMu.Lock()
+	jitter := time.Duration(rng.Int63n(int64(delay)/2 + 1))
+	rngMu.Unlock()
+
+	return delay + jitter
+}
+
+// maxRetryAfter caps the wait a server can ask for with Retry-After
+var maxRetryAfter = 2 * time.Minute
+
+// parseRetryAfter reads the Retry-After header of a 429 response, given
+// either as a number of seconds or as an HTTP-date, capped at maxRetryAfter
+func parseRetryAfter(res *http.Response) (time.Duration, bool) {
+	if res.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	value := strings.TrimSpace(res.Header.Get("Retry-After"))
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		// A date already in the past means retry straight away
+		wait = max(time.Until(date), 0)
+	} else {
+		return 0, false
+	}
+	return min(wait, maxRetryAfter), true
+}
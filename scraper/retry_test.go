@@ -0,0 +1,226 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryTransientFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><img src="/a.png"></body></html>`)
+	}))
+	defer server.Close()
+
+	resp, err := makeRequest(server.URL + "/flaky")
+	if err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("server got %d requests, want 3", got)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	resp, err := makeRequest(server.URL + "/down")
+	if err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want the final 500", resp.StatusCode)
+	}
+	if got := requests.Load(); got != DefaultMaxAttempts {
+		t.Errorf("server got %d requests, want %d", got, DefaultMaxAttempts)
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		maxAttempts int
+		want        int32
+	}{
+		{5, 5},
+		{1, 1},
+		// Less than one still sends the request once
+		{-1, 1},
+	}
+	for _, tt := range tests {
+		requests.Store(0)
+		s := &Scraper{MaxAttempts: tt.maxAttempts, RetryDelay: time.Millisecond}
+		resp, err := s.makeRequest(context.Background(), server.URL+"/down")
+		if err != nil {
+			t.Fatalf("makeRequest: %v", err)
+		}
+		resp.Body.Close()
+		if got := requests.Load(); got != tt.want {
+			t.Errorf("MaxAttempts %d sent %d requests, want %d", tt.maxAttempts, got, tt.want)
+		}
+	}
+}
+
+func TestRetryNotForCertificateErrors(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	// The test server's certificate isn't trusted by the default client
+	if resp, err := makeRequest(server.URL + "/page"); err == nil {
+		resp.Body.Close()
+		t.Fatal("makeRequest of an untrusted server returned no error")
+	}
+	if got := connections.Load(); got != 1 {
+		t.Errorf("certificate error was tried %d times, want once", got)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Get", URL: "http://example.com", Err: refused}, true},
+		{&url.Error{Op: "Get", URL: "http://example.com", Err: io.EOF}, true},
+		{fmt.Errorf("reading: %w", context.DeadlineExceeded), true},
+		{&net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}, true},
+		{&net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}, false},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, false},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}}, false},
+		{&url.Error{Op: "Get", URL: "ftp://example.com", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{fmt.Errorf("stopped: %w", errTooManyRedirects), false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := shouldRetry(nil, tt.err); got != tt.want {
+			t.Errorf("shouldRetry(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+
+	for status, want := range map[int]bool{200: false, 404: false, 429: true, 500: true, 503: true} {
+		if got := shouldRetry(&http.Response{StatusCode: status}, nil); got != want {
+			t.Errorf("shouldRetry(status %d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestRetryNotFound(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	resp, err := makeRequest(server.URL + "/gone")
+	if err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	resp.Body.Close()
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server got %d requests for a 404, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		status int
+		value  string
+		want   time.Duration
+		ok     bool
+	}{
+		{http.StatusTooManyRequests, "120", 120 * time.Second, true},
+		{http.StatusTooManyRequests, " 0 ", 0, true},
+		{http.StatusTooManyRequests, "86400", maxRetryAfter, true},
+		{http.StatusTooManyRequests, time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+		{http.StatusTooManyRequests, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), maxRetryAfter, true},
+		{http.StatusTooManyRequests, "-5", 0, false},
+		{http.StatusTooManyRequests, "soon", 0, false},
+		{http.StatusTooManyRequests, "", 0, false},
+		{http.StatusServiceUnavailable, "120", 0, false},
+	}
+	for _, tt := range tests {
+		res := &http.Response{StatusCode: tt.status, Header: http.Header{"Retry-After": {tt.value}}}
+		wait, ok := parseRetryAfter(res)
+		if wait != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%d, %q) = %v, %v, want %v, %v", tt.status, tt.value, wait, ok, tt.want, tt.ok)
+		}
+	}
+
+	// An HTTP-date in the near future waits until then
+	res := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{
+		"Retry-After": {time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)},
+	}}
+	if wait, ok := parseRetryAfter(res); !ok || wait < 28*time.Second || wait > 30*time.Second {
+		t.Errorf("parseRetryAfter of a date 30s ahead = %v, %v, want about 30s", wait, ok)
+	}
+}
+
+func TestRetryAfterHonored(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body></body></html>`)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	s := &Scraper{IgnoreRobots: true}
+	if _, errs := s.Scrape([]string{server.URL + "/limited"}); len(errs) != 0 {
+		t.Fatalf("Scrape errors = %v, want none", errs)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retry came after %v, want the 1s the server asked for", elapsed)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server got %d requests, want 2", got)
+	}
+}
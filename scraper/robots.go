@@ -0,0 +1,296 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsUserAgent is the product token matched against robots.txt groups
+const robotsUserAgent = "GOImageScrape"
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// robotsGroup is a set of rules that apply to one or more user agents
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// maxRobotsCrawlDelay caps the Crawl-delay taken from robots.txt so a huge
+// value can't stall a scrape indefinitely
+const maxRobotsCrawlDelay = time.Minute
+
+// RobotsRules holds the parsed contents of a robots.txt file
+type RobotsRules struct {
+	groups []robotsGroup
+	// Sitemaps lists the URLs given by Sitemap: lines
+	Sitemaps []string
+}
+
+// parseRobots reads a robots.txt file into its user-agent groups
+func parseRobots(r io.Reader) *RobotsRules {
+	rules := &RobotsRules{}
+	var current *robotsGroup
+	// Consecutive User-agent lines share the rules that follow them
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Strip comments and surrounding whitespace
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				rules.groups = append(rules.groups, robotsGroup{})
+				current = &rules.groups[len(rules.groups)-1]
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			// An empty Disallow allows everything, so it adds no rule
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			seconds, err := strconv.ParseFloat(value, 64)
+			if current == nil || err != nil || seconds < 0 {
+				continue
+			}
+			current.crawlDelay = min(time.Duration(seconds*float64(time.Second)), maxRobotsCrawlDelay)
+		case "sitemap":
+			// Sitemap lines stand alone and don't belong to any group
+			if value != "" {
+				rules.Sitemaps = append(rules.Sitemaps, value)
+			}
+		default:
+			inAgents = false
+		}
+	}
+	return rules
+}
+
+// robotsPattern compiles a robots.txt path into a regexp anchored at the start
+// of the path, supporting the * wildcard and the $ end-of-path anchor
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// productToken returns the name part of a user agent such as
+// "GOImageScrape/1.0", in lower case
+func productToken(agent string) string {
+	token, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(agent)), "/")
+	return token
+}
+
+// group returns the rules for agent. A group naming the agent's product token
+// wins over the catch-all * group.
+func (r *RobotsRules) group(agent string) *robotsGroup {
+	token := productToken(agent)
+	var fallback *robotsGroup
+	for i := range r.groups {
+		for _, name := range r.groups[i].agents {
+			if name == "*" {
+				if fallback == nil {
+					fallback = &r.groups[i]
+				}
+			} else if name != "" && productToken(name) == token {
+				return &r.groups[i]
+			}
+		}
+	}
+	return fallback
+}
+
+// Allowed reports whether agent may fetch path. The longest matching rule
+// decides, with Allow winning ties.
+func (r *RobotsRules) Allowed(agent, path string) bool {
+	group := r.group(agent)
+	if group == nil {
+		return true
+	}
+
+	allowed := true
+	best := -1
+	for _, rule := range group.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			best = rule.length
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay robots.txt asks agent to leave between
+// requests, or 0 when it gives none
+func (r *RobotsRules) CrawlDelay(agent string) time.Duration {
+	group := r.group(agent)
+	if group == nil {
+		return 0
+	}
+	return group.crawlDelay
+}
+
+// fetchRobots downloads and parses the robots.txt at the root of site
+// (scheme://host). A robots.txt that doesn't exist gives empty rules; a
+// server error gives a StatusError.
+func (s *Scraper) fetchRobots(ctx context.Context, site string) (*RobotsRules, error) {
+	resp, err := s.makeRequest(ctx, site+"/robots.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &RobotsRules{}, nil
+	}
+	return parseRobots(resp.Body), nil
+}
+
+// DiscoverSitemaps returns the http and https sitemap URLs declared in the
+// robots.txt of the site serving siteURL
+func (s *Scraper) DiscoverSitemaps(siteURL string) ([]string, error) {
+	parsed, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("site URL %q has no host", siteURL)
+	}
+
+	rules, err := s.fetchRobots(context.Background(), parsed.Scheme+"://"+parsed.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sitemap lines come from the remote site, so anything other than an
+	// http(s) URL is dropped rather than read as a local file
+	var sitemaps []string
+	for _, sitemap := range rules.Sitemaps {
+		if !isHTTPURL(sitemap) {
+			s.log().Warn("Skipping robots.txt sitemap that isn't an http or https URL", "site", parsed.Host, "sitemap", sitemap)
+			continue
+		}
+		sitemaps = append(sitemaps, sitemap)
+	}
+	return sitemaps, nil
+}
+
+// robotsEntry is a cached robots.txt, or one still being fetched. done is
+// closed once rules is set.
+type robotsEntry struct {
+	done  chan struct{}
+	rules *RobotsRules
+}
+
+// disallowAll returns rules that block every path for every agent
+func disallowAll() *RobotsRules {
+	return &RobotsRules{groups: []robotsGroup{{
+		agents: []string{"*"},
+		rules:  []robotsRule{{length: 1, pattern: robotsPattern("/")}},
+	}}}
+}
+
+// robotsFor fetches and caches the robots.txt of the host serving pageURL.
+// Concurrent calls for one host share a single fetch. A missing robots.txt
+// allows everything. A server error disallows everything, as RFC 9309 asks;
+// any other failure allows everything. Failures are cached for the run too, so
+// a broken robots.txt isn't fetched again for every page.
+func (s *Scraper) robotsFor(ctx context.Context, pageURL *url.URL) *RobotsRules {
+	key := pageURL.Scheme + "://" + pageURL.Host
+
+	s.robotsMu.Lock()
+	entry, ok := s.robots[key]
+	if !ok {
+		if s.robots == nil {
+			s.robots = make(map[string]*robotsEntry)
+		}
+		entry = &robotsEntry{done: make(chan struct{})}
+		s.robots[key] = entry
+	}
+	s.robotsMu.Unlock()
+
+	// Another request is already fetching it
+	if ok {
+		select {
+		case <-entry.done:
+			return entry.rules
+		case <-ctx.Done():
+			return &RobotsRules{}
+		}
+	}
+
+	rules, err := s.fetchRobots(ctx, key)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		s.log().Warn("Server error fetching robots.txt, disallowing all URLs", "site", key, "err", err)
+		rules = disallowAll()
+	} else if err != nil {
+		s.log().Debug("Error fetching robots.txt, allowing all URLs", "site", key, "err", err)
+		rules = &RobotsRules{}
+	}
+	entry.rules = rules
+	close(entry.done)
+	return rules
+}
+
+// robotsAllowed reports whether robots.txt permits scraping rawURL
+func (s *Scraper) robotsAllowed(ctx context.Context, rawURL string) bool {
+	pageURL, err := url.Parse(rawURL)
+	if err != nil || pageURL.Host == "" {
+		return true
+	}
+
+	path := pageURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if pageURL.RawQuery != "" {
+		path += "?" + pageURL.RawQuery
+	}
+	return s.robotsFor(ctx, pageURL).Allowed(robotsUserAgent, path)
+}
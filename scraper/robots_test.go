@@ -0,0 +1,179 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: GOImageScrape
+User-agent: otherbot
+Disallow: /nobots # comment
+Crawl-delay: 0.5
+`))
+
+	tests := []struct {
+		agent, path string
+		want        bool
+	}{
+		{"SomeBot/1.0", "/", true},
+		{"SomeBot/1.0", "/private/secret", false},
+		{"SomeBot/1.0", "/private/public/page", true},
+		{"SomeBot/1.0", "/docs/report.pdf", false},
+		{"SomeBot/1.0", "/docs/report.pdf?download=1", true},
+		{"SomeBot/1.0", "/nobots", true},
+		// The named group replaces the * group rather than adding to it
+		{robotsUserAgent, "/private/secret", true},
+		{robotsUserAgent, "/nobots/page", false},
+		{"OtherBot", "/nobots", false},
+		// Agents match on the exact product token, ignoring case and version
+		{"goimagescrape/2.0", "/nobots", false},
+		{"NotGOImageScrape", "/nobots", true},
+		{"GOImage", "/nobots", true},
+	}
+	for _, tt := range tests {
+		if got := rules.Allowed(tt.agent, tt.path); got != tt.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+
+	empty := parseRobots(strings.NewReader("User-agent:\nDisallow: /\n"))
+	if !empty.Allowed(robotsUserAgent, "/") {
+		t.Error("an empty User-agent line matched every agent")
+	}
+
+	if got := rules.CrawlDelay("SomeBot"); got != 2*time.Second {
+		t.Errorf("CrawlDelay(SomeBot) = %v, want 2s", got)
+	}
+	if got := rules.CrawlDelay(robotsUserAgent); got != 500*time.Millisecond {
+		t.Errorf("CrawlDelay(%s) = %v, want 500ms", robotsUserAgent, got)
+	}
+}
+
+func TestScrapeRobotsDisallowed(t *testing.T) {
+	var robotsFetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			// Hold the first fetch so the other workers have to wait on it
+			time.Sleep(20 * time.Millisecond)
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><img src="/a.png"></body></html>`)
+	}))
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 10; i++ {
+		urls = append(urls, fmt.Sprintf("%s/public/%d", server.URL, i), fmt.Sprintf("%s/private/%d", server.URL, i))
+	}
+	results, errs := (&Scraper{Concurrency: 8}).Scrape(urls)
+	if len(results) != 10 {
+		t.Errorf("got %d results, want the 10 public pages", len(results))
+	}
+	for _, result := range results {
+		if strings.Contains(result.URL, "/private/") {
+			t.Errorf("disallowed page %s was scraped", result.URL)
+		}
+	}
+	if len(errs) != 10 {
+		t.Errorf("got %d errors, want the 10 private pages", len(errs))
+	}
+	for url, err := range errs {
+		if !errors.Is(err, errRobotsDisallowed) {
+			t.Errorf("%s failed with %v, want errRobotsDisallowed", url, err)
+		}
+	}
+	if got := robotsFetches.Load(); got != 1 {
+		t.Errorf("robots.txt was fetched %d times, want once", got)
+	}
+}
+
+func TestRobotsFetchFailureCached(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	page, err := url.Parse(server.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scraper{}
+	if s.robotsFor(context.Background(), page).Allowed(robotsUserAgent, "/page") {
+		t.Error("a robots.txt server error allowed the page")
+	}
+	before := fetches.Load()
+	for range 3 {
+		if s.robotsFor(context.Background(), page).Allowed(robotsUserAgent, "/page") {
+			t.Error("a cached robots.txt server error allowed the page")
+		}
+	}
+	if got := fetches.Load() - before; got != 0 {
+		t.Errorf("robots.txt was fetched %d more times after failing, want none", got)
+	}
+}
+
+func TestRobotsUnreachableAllowsAll(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	page, err := url.Parse(server.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	s := &Scraper{}
+	if !s.robotsFor(context.Background(), page).Allowed(robotsUserAgent, "/page") {
+		t.Error("an unreachable robots.txt disallowed the page")
+	}
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+	if _, ok := s.robots[server.URL]; !ok {
+		t.Error("the failed robots.txt fetch wasn't cached")
+	}
+}
+
+func TestDiscoverSitemaps(t *testing.T) {
+	server := serveFiles(t, map[string]string{"/robots.txt": `User-agent: *
+Disallow: /admin/
+Sitemap: https://example.com/sitemap-news.xml
+
+sitemap:https://example.com/sitemap-pages.xml # pages
+Sitemap:
+Sitemap: /etc/passwd
+Sitemap: file:///etc/passwd
+`})
+
+	sitemaps, err := (&Scraper{}).DiscoverSitemaps(server.URL + "/any/page?x=1")
+	if err != nil {
+		t.Fatalf("DiscoverSitemaps: %v", err)
+	}
+	want := []string{"https://example.com/sitemap-news.xml", "https://example.com/sitemap-pages.xml"}
+	if !slices.Equal(sitemaps, want) {
+		t.Errorf("DiscoverSitemaps = %q, want %q", sitemaps, want)
+	}
+
+	if _, err := (&Scraper{}).DiscoverSitemaps("not a url"); err == nil {
+		t.Error("DiscoverSitemaps of a URL without a host returned no error")
+	}
+}
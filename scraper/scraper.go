@@ -0,0 +1,862 @@
+// Package scraper fetches web pages, from a sitemap, a URL list or by
+// following links, and extracts the images and other media they reference.
+//
+// A Scraper holds the request settings and runs the pages through a Parser,
+// DefaultParser unless another is given. The package level functions such as
+// ScrapeImages use DefaultScraper.
+package scraper
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scraper holds the configuration shared by every page fetch. The zero value
+// is ready to use: it scrapes with DefaultParser, DefaultConcurrency workers,
+// the built-in User-Agent list and the package logger.
+type Scraper struct {
+	// Client sends every request. When nil a client is built on first use
+	// from the timeout and cookie settings below.
+	Client *http.Client
+	// Transport, when set, carries the requests of the built client in
+	// place of the default transport, so requests can be recorded or faked
+	// while the timeout, redirect and cookie settings still apply. The
+	// connection pool, ConnectTimeout, HeaderTimeout, Proxy, TLSConfig and
+	// ForceHTTP1 settings are then up to it.
+	Transport http.RoundTripper
+	// Parser extracts the media data from each page; nil means DefaultParser
+	Parser Parser
+	// Concurrency is the number of pages fetched at once; zero or less means
+	// DefaultConcurrency
+	Concurrency int
+	// MaxConcurrency caps Concurrency; zero means DefaultMaxConcurrency
+	MaxConcurrency int
+	// Adaptive, when set, varies the number of pages fetched at once with
+	// the error rate, between its bounds, instead of keeping it fixed
+	Adaptive *AdaptiveConcurrency
+	// UserAgents are picked from for each request; an empty list
+	// means the built-in DefaultUserAgents list
+	UserAgents []string
+	// UserAgentRotation chooses how each request picks from UserAgents:
+	// "random" (the default when empty), "round-robin" to cycle through them
+	// in order, or "fixed" to always send the first
+	UserAgentRotation string
+	// Logger receives progress and error messages; nil means the package logger
+	Logger *slog.Logger
+	// Progress, when set, is called each time a URL is finished, whether it
+	// succeeded or failed, with the number done so far and the number of
+	// URLs in the scrape. Calls are made one at a time with done increasing
+	// by one each call. A crawl reports each depth as a separate scrape.
+	Progress func(done, total int)
+	// StartJitter spreads out the first requests of a scrape: each worker
+	// waits a random time up to StartJitter before its first URL so they
+	// don't all hit the host at once. Zero means no wait.
+	StartJitter time.Duration
+
+	// Headers are added to every request. A User-Agent given here is used
+	// instead of a randomly chosen one.
+	Headers http.Header
+	// Jar keeps cookies set by servers between requests; nil disables it
+	Jar http.CookieJar
+	// Username and Password are sent as HTTP Basic Auth when Username is set
+	Username string
+	Password string
+	// BearerToken, when set, is sent as "Authorization: Bearer <token>".
+	// It takes the place of Basic Auth, so set only one of the two.
+	BearerToken string
+
+	// Timeout limits each whole request, including reading the body.
+	// Zero means DefaultTimeout.
+	Timeout time.Duration
+	// ConnectTimeout limits establishing the connection; zero means no
+	// separate limit
+	ConnectTimeout time.Duration
+	// HeaderTimeout limits waiting for the response headers once the request
+	// is sent; zero means no separate limit
+	HeaderTimeout time.Duration
+	// Proxy routes every request through an http, https or socks5 proxy.
+	// When nil the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables are used.
+	Proxy *url.URL
+	// MaxIdleConns limits the keep-alive connections pooled across all
+	// hosts; zero means no limit
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits the keep-alive connections pooled for each
+	// host; zero means DefaultMaxIdleConnsPerHost
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long a pooled connection may sit unused before
+	// it is closed; zero means DefaultIdleConnTimeout
+	IdleConnTimeout time.Duration
+	// MaxConnsPerHost limits the connections open to each host at once,
+	// busy or idle; zero means no limit
+	MaxConnsPerHost int
+	// TLSConfig, when set, is used for TLS connections in place of the
+	// default, e.g. to trust extra certificate authorities or to skip
+	// certificate verification for internal hosts
+	TLSConfig *tls.Config
+	// ForceHTTP1 speaks HTTP/1.1 even to servers offering HTTP/2, for
+	// servers and proxies that mishandle it
+	ForceHTTP1 bool
+	// Cache, when set, makes page requests conditional on the validators
+	// from an earlier run and reuses the cached data for unchanged pages
+	Cache *ResponseCache
+	// HAR, when set, records every page request and response
+	HAR *HARRecorder
+	// Breaker, when set, stops requests to hosts that keep failing
+	Breaker *CircuitBreaker
+	// ParseErrorPages parses pages served with a status outside 2xx instead
+	// of failing them with a StatusError
+	ParseErrorPages bool
+	// IgnoreTrailingSlash treats sitemap URLs that differ only by a trailing
+	// slash as the same page
+	IgnoreTrailingSlash bool
+	// IgnoreRobots scrapes URLs that robots.txt disallows and ignores its
+	// Crawl-delay
+	IgnoreRobots bool
+	// RateLimiter throttles page requests per host; nil means no limit
+	RateLimiter *HostRateLimiter
+	// Delay is the minimum interval between requests to one host, used in
+	// place of any robots.txt Crawl-delay; zero means use robots.txt
+	Delay time.Duration
+	// MaxAttempts is how many times a request is tried when it times out,
+	// loses its connection or gets a 429 or 5xx response. Zero means
+	// DefaultMaxAttempts and 1 or less means no retries.
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry; it doubles on each
+	// attempt. Zero means DefaultRetryDelay.
+	RetryDelay time.Duration
+	// MaxRedirects is the longest redirect chain a request will follow.
+	// Zero means DefaultMaxRedirects and less than zero follows none.
+	MaxRedirects int
+	// MaxPageSize is the most of a page body, in bytes, that is parsed.
+	// Anything past it is dropped so one huge response can't exhaust memory.
+	// Zero means DefaultMaxPageSize and less than zero means no limit.
+	MaxPageSize int64
+	// MaxContentLength skips pages whose Content-Length is larger, without
+	// reading them at all; zero means no limit. Pages that don't send a
+	// Content-Length are still read up to MaxPageSize.
+	MaxContentLength int64
+	// MaxImageSize is the largest image, in bytes, that is downloaded or
+	// hashed; zero means DefaultMaxImageSize
+	MaxImageSize int64
+
+	// The client is built on first use from the fields above and shared by
+	// all requests so connections are kept alive and reused
+	clientOnce sync.Once
+	client     *http.Client
+	// nextAgent counts requests for round-robin User-Agent rotation
+	nextAgent atomic.Uint64
+	// limiter adjusts the concurrency when Adaptive is set
+	limiterOnce sync.Once
+	limiter     *aimdLimiter
+	// hostDelays spaces out the requests made to each host
+	hostDelays HostDelays
+	// robots caches the parsed robots.txt of each host already seen
+	robotsMu sync.Mutex
+	robots   map[string]*robotsEntry
+}
+
+// DefaultConcurrency is the worker count used when Scraper.Concurrency is
+// zero. It is kept low so a scrape doesn't look like an attack to the site.
+const DefaultConcurrency = 8
+
+// DefaultMaxConcurrency is the worker cap used when Scraper.MaxConcurrency is
+// zero. Past this, more workers mostly add open sockets, not speed.
+const DefaultMaxConcurrency = 500
+
+// DefaultTimeout is the request timeout used when Scraper.Timeout is zero
+const DefaultTimeout = 10 * time.Second
+
+// DefaultMaxIdleConnsPerHost is how many keep-alive connections are pooled
+// for each host when Scraper.MaxIdleConnsPerHost is zero. The net/http
+// default of 2 forces most concurrent requests to the same site to open new
+// connections.
+const DefaultMaxIdleConnsPerHost = 64
+
+// DefaultIdleConnTimeout is how long pooled connections are kept when
+// Scraper.IdleConnTimeout is zero, the same as net/http's default
+const DefaultIdleConnTimeout = 90 * time.Second
+
+// DefaultMaxRedirects is the redirect limit used when Scraper.MaxRedirects is
+// zero
+const DefaultMaxRedirects = 10
+
+// DefaultMaxPageSize is the page size limit used when Scraper.MaxPageSize is
+// zero
+const DefaultMaxPageSize = 10 << 20
+
+// DefaultMaxImageSize is the image size limit used when Scraper.MaxImageSize
+// is zero
+const DefaultMaxImageSize = 20 << 20
+
+// DefaultScraper is used by the package level functions such as makeRequest
+// and ScrapeImages
+var DefaultScraper = &Scraper{}
+
+// DefaultUserAgents are picked from for requests when Scraper.UserAgents is empty
+var DefaultUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/61.0.3163.100 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/61.0.3163.100 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:56.0) Gecko/20100101 Firefox/56.0",
+}
+
+// rng is seeded once at startup and shared by all scraping goroutines.
+// *rand.Rand is not safe for concurrent use, so access goes through rngMu.
+var (
+	rng   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	rngMu sync.Mutex
+)
+
+// userAgent picks the User-Agent for the next request as UserAgentRotation
+// asks
+func (s *Scraper) userAgent() string {
+	agents := s.UserAgents
+	if len(agents) == 0 {
+		agents = DefaultUserAgents
+	}
+
+	switch s.UserAgentRotation {
+	case "fixed":
+		return agents[0]
+	case "round-robin":
+		next := s.nextAgent.Add(1) - 1
+		return agents[next%uint64(len(agents))]
+	}
+
+	rngMu.Lock()
+	randNum := rng.Intn(len(agents))
+	rngMu.Unlock()
+	return agents[randNum]
+}
+
+// UserAgentRotations are the values accepted for Scraper.UserAgentRotation
+var UserAgentRotations = map[string]bool{"": true, "random": true, "round-robin": true, "fixed": true}
+
+// log returns the scraper's logger
+func (s *Scraper) log() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return Logger
+}
+
+// parser returns the parser pages are run through. A DefaultParser without
+// a logger of its own logs to the scraper's.
+func (s *Scraper) parser() Parser {
+	switch p := s.Parser.(type) {
+	case nil:
+		return DefaultParser{Logger: s.Logger}
+	case DefaultParser:
+		if p.Logger == nil {
+			p.Logger = s.Logger
+		}
+		return p
+	case LinkParser:
+		if p.Logger == nil {
+			p.Logger = s.Logger
+		}
+		return p
+	}
+	return s.Parser
+}
+
+// errTooManyRedirects is returned when a redirect chain exceeds MaxRedirects
+var errTooManyRedirects = errors.New("too many redirects")
+
+// checkRedirect stops following redirects after MaxRedirects hops
+func (s *Scraper) checkRedirect(req *http.Request, via []*http.Request) error {
+	limit := s.MaxRedirects
+	if limit == 0 {
+		limit = DefaultMaxRedirects
+	}
+	if len(via) >= limit {
+		return fmt.Errorf("stopped after %d redirects: %w", max(limit, 0), errTooManyRedirects)
+	}
+	return nil
+}
+
+// maxPageSize returns the page size limit, applying the default
+func (s *Scraper) maxPageSize() int64 {
+	if s.MaxPageSize == 0 {
+		return DefaultMaxPageSize
+	}
+	return s.MaxPageSize
+}
+
+// maxImageSize returns the image size limit, applying the default
+func (s *Scraper) maxImageSize() int64 {
+	if s.MaxImageSize == 0 {
+		return DefaultMaxImageSize
+	}
+	return s.MaxImageSize
+}
+
+// makeRequest sends an HTTP GET request with a random User-Agent header
+func makeRequest(url string) (*http.Response, error) {
+	return makeRequestContext(context.Background(), url)
+}
+
+// makeRequestContext is like makeRequest but aborts the request when ctx is
+// cancelled
+func makeRequestContext(ctx context.Context, url string) (*http.Response, error) {
+	return DefaultScraper.makeRequest(ctx, url)
+}
+
+// httpClient returns the scraper's client. Unless Client was set, it is built
+// on first use and configuration changes made after that have no effect.
+func (s *Scraper) httpClient() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	s.clientOnce.Do(func() {
+		transport := s.Transport
+		if transport == nil {
+			transport = s.newTransport()
+		}
+
+		timeout := s.Timeout
+		if timeout == 0 {
+			timeout = DefaultTimeout
+		}
+		s.client = &http.Client{
+			Transport:     transport,
+			Timeout:       timeout,
+			CheckRedirect: s.checkRedirect,
+			Jar:           s.Jar,
+		}
+	})
+	return s.client
+}
+
+// newTransport builds the transport for the scraper's connection pool,
+// timeout and proxy settings
+func (s *Scraper) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = s.MaxIdleConns
+	transport.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	if transport.MaxIdleConnsPerHost == 0 {
+		transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = s.IdleConnTimeout
+	if transport.IdleConnTimeout == 0 {
+		transport.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	transport.MaxConnsPerHost = s.MaxConnsPerHost
+	if s.ConnectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   s.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = s.ConnectTimeout
+	}
+	transport.ResponseHeaderTimeout = s.HeaderTimeout
+	if s.Proxy != nil {
+		transport.Proxy = http.ProxyURL(s.Proxy)
+	}
+	if s.TLSConfig != nil {
+		transport.TLSClientConfig = s.TLSConfig.Clone()
+	}
+	// An empty, non-nil TLSNextProto keeps HTTP/2 from being negotiated
+	if s.ForceHTTP1 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// makeRequest sends an HTTP GET request with the scraper's headers
+func (s *Scraper) makeRequest(ctx context.Context, url string) (*http.Response, error) {
+	return s.makeRequestHeaders(ctx, url, nil)
+}
+
+// makeRequestHeaders is like makeRequest but also sends the given headers
+func (s *Scraper) makeRequestHeaders(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	return s.sendRequest(ctx, http.MethodGet, url, header)
+}
+
+// sendRequest is like makeRequestHeaders but for any request method
+func (s *Scraper) sendRequest(ctx context.Context, method, url string, header http.Header) (*http.Response, error) {
+	return s.send(ctx, method, url, header, s.Breaker)
+}
+
+// send is sendRequest with the failures counted by breaker, which may be nil
+// to leave the request out of any breaker
+func (s *Scraper) send(ctx context.Context, method, url string, header http.Header, breaker *CircuitBreaker) (*http.Response, error) {
+
+	// Uses the shared HTTP client so connections are reused between requests
+	client := s.httpClient()
+
+	// HTTP Request for thee url given
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the custom headers, then set the User-Agent Header to the randomly
+	// chosen agent unless one was supplied.
+	for _, headers := range []http.Header{s.Headers, header} {
+		for name, values := range headers {
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", s.userAgent())
+	}
+	if s.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.BearerToken)
+	} else if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+
+	// Requests to a host the breaker has given up on aren't sent at all
+	host := breakerHost(url)
+	if !breaker.allow(host) {
+		return nil, fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	}
+
+	// Sends the HTTP get request, retrying transient failures with backoff.
+	// Retrying stops early once the failures open the host's breaker.
+	for attempt := 1; ; attempt++ {
+		res, err := client.Do(req)
+		failed := shouldRetry(res, err) && ctx.Err() == nil
+		opened := breaker.record(host, failed)
+		if opened {
+			s.log().Warn("Host keeps failing, pausing requests to it", "host", host, "cooldown", breaker.Cooldown)
+		}
+		if attempt >= s.maxAttempts() || !shouldRetry(res, err) || opened {
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		}
+
+		wait := s.backoffDelay(attempt)
+		if res != nil {
+			if retryAfter, ok := parseRetryAfter(res); ok {
+				wait = retryAfter
+			}
+			res.Body.Close()
+		}
+		s.log().Warn("Retrying URL", "url", url, "wait", wait, "attempt", attempt+1, "max_attempts", s.maxAttempts())
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Scrape fetches image data from a list of URLs using the scraper's parser
+// and concurrency. Pages that were scraped successfully are returned as
+// results; every URL that failed is returned in the errors map with the
+// reason it failed.
+func (s *Scraper) Scrape(urls []string) ([]MediaData, map[string]error) {
+	return s.ScrapeContext(context.Background(), urls)
+}
+
+// ScrapeContext is like Scrape but stops when ctx is cancelled
+func (s *Scraper) ScrapeContext(ctx context.Context, urls []string) ([]MediaData, map[string]error) {
+	return s.scrape(ctx, urls, s.parser(), s.Concurrency)
+}
+
+// ScrapeSitemap parses the sitemap at sitemapURL and scrapes every page it
+// lists. The error is set only when the sitemap itself can't be read.
+func (s *Scraper) ScrapeSitemap(sitemapURL string) ([]MediaData, map[string]error, error) {
+	urls, err := s.ParseSitemap(sitemapURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	results, errs := s.Scrape(urls)
+	return results, errs, nil
+}
+
+// ScrapeImages fetches image data from a list of URLs. Pages that were
+// scraped successfully are returned as results; every URL that failed is
+// returned in the errors map with the reason it failed.
+func ScrapeImages(urls []string, parser Parser, concurrency int) ([]MediaData, map[string]error) {
+	return ScrapeImagesContext(context.Background(), urls, parser, concurrency)
+}
+
+// ScrapeImagesContext is like ScrapeImages but stops when ctx is cancelled.
+// In-flight requests are aborted, no further URLs are started, and the results
+// collected so far are returned.
+func ScrapeImagesContext(ctx context.Context, urls []string, parser Parser, concurrency int) ([]MediaData, map[string]error) {
+	return DefaultScraper.scrape(ctx, urls, parser, concurrency)
+}
+
+// ScrapeImagesStream is like ScrapeImages but emits each MediaData on the
+// returned channel as soon as its page is done, so callers don't have to hold
+// every result in memory. The channel is closed once all URLs are processed.
+// Failed URLs are logged and produce nothing on the channel.
+func ScrapeImagesStream(urls []string, parser Parser, concurrency int) <-chan MediaData {
+	return DefaultScraper.stream(context.Background(), urls, parser, concurrency, nil)
+}
+
+// ScrapeStream is like Scrape but emits each MediaData on the returned channel
+// as its page completes. onError, when not nil, is called for each URL that
+// fails, one call at a time but concurrently with the channel being read.
+// The channel must be read until it is closed, otherwise the workers block.
+func (s *Scraper) ScrapeStream(ctx context.Context, urls []string, onError func(url string, err error)) <-chan MediaData {
+	return s.stream(ctx, urls, s.parser(), s.Concurrency, onError)
+}
+
+// PageResult is the outcome of scraping one URL: its media data, or the
+// error it failed with
+type PageResult struct {
+	URL  string
+	Data MediaData
+	Err  error
+}
+
+// ScrapeResults is like ScrapeStream but sends failed URLs on the same
+// channel as the scraped pages, so a single reader sees every outcome and
+// needs no locking. The channel must be read until it is closed.
+func (s *Scraper) ScrapeResults(ctx context.Context, urls []string) <-chan PageResult {
+	return s.results(ctx, urls, s.parser(), s.Concurrency)
+}
+
+// scrape collects everything produced by results into a results slice and
+// an errors map. Only this goroutine touches them, so they need no lock.
+func (s *Scraper) scrape(ctx context.Context, urls []string, parser Parser, concurrency int) ([]MediaData, map[string]error) {
+	results := []MediaData{}
+	errs := make(map[string]error)
+	for res := range s.results(ctx, urls, parser, concurrency) {
+		if res.Err != nil {
+			errs[res.URL] = res.Err
+			continue
+		}
+		results = append(results, res.Data)
+	}
+	return results, errs
+}
+
+// stream is like results but sends only the successful pages, passing the
+// failed URLs to onError
+func (s *Scraper) stream(ctx context.Context, urls []string, parser Parser, concurrency int, onError func(url string, err error)) <-chan MediaData {
+	out := make(chan MediaData)
+	go func() {
+		defer close(out)
+		for res := range s.results(ctx, urls, parser, concurrency) {
+			if res.Err != nil {
+				if onError != nil {
+					onError(res.URL, res.Err)
+				}
+				continue
+			}
+			out <- res.Data
+		}
+	}()
+	return out
+}
+
+// results runs the worker pool behind every scrape function. The outcome of
+// each URL is sent on the returned channel, which is closed when all workers
+// are done.
+func (s *Scraper) results(ctx context.Context, urls []string, parser Parser, concurrency int) <-chan PageResult {
+	out := make(chan PageResult)
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	concurrency = s.boundConcurrency(concurrency)
+
+	// In adaptive mode the pool is sized for the most requests allowed
+	// and the limiter decides how many of them run
+	limiter := s.adaptiveLimiter(concurrency)
+	if limiter != nil {
+		concurrency = limiter.hi
+	}
+
+	// Count finished URLs under a lock so Progress sees them in order
+	var progressMu sync.Mutex
+	done := 0
+	finished := func() {
+		if s.Progress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		done++
+		s.Progress(done, len(urls))
+	}
+
+	// Start a fixed pool of workers that pull URLs from the jobs channel
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first := true
+			for url := range jobs {
+				if first {
+					s.waitStartJitter(ctx)
+					first = false
+				}
+				if limiter != nil {
+					limiter.acquire()
+				}
+				data, err := s.scrapeURL(ctx, url, parser)
+				if limiter != nil {
+					if limit, changed := limiter.release(err); changed {
+						s.log().Info("Adjusted concurrency", "concurrency", limit)
+					}
+				}
+				finished()
+				out <- PageResult{URL: url, Data: data, Err: err}
+			}
+		}()
+	}
+
+	// Feed the URLs to the workers until they run out or ctx is cancelled,
+	// then close the output once the workers have finished
+	go func() {
+	feed:
+		for _, url := range urls {
+			select {
+			case jobs <- url:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// waitStartJitter sleeps for a random part of StartJitter, returning early
+// when ctx is cancelled
+func (s *Scraper) waitStartJitter(ctx context.Context) {
+	if s.StartJitter <= 0 {
+		return
+	}
+	rngMu.Lock()
+	wait := time.Duration(rng.Int63n(int64(s.StartJitter)))
+	rngMu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// boundConcurrency turns a requested worker count into a usable one. Without
+// at least one worker nothing would ever take the queued URLs.
+func (s *Scraper) boundConcurrency(concurrency int) int {
+	if concurrency <= 0 {
+		return DefaultConcurrency
+	}
+	limit := s.MaxConcurrency
+	if limit <= 0 {
+		limit = DefaultMaxConcurrency
+	}
+	if concurrency > limit {
+		s.log().Warn("Concurrency capped", "requested", concurrency, "max", limit)
+		return limit
+	}
+	return concurrency
+}
+
+// maxDrainBytes is how much of an unread body is discarded so its connection
+// can be reused; larger leftovers are cheaper to drop with the connection
+const maxDrainBytes = 64 << 10
+
+// closeBody drains what is left of a response body and closes it. Closing an
+// already closed body is harmless.
+func closeBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	resp.Body.Close()
+}
+
+// decodeBody replaces a gzip encoded response body with its decompressed
+// content, as the transport does for requests that don't set their own
+// Accept-Encoding. Bodies the transport already decompressed are left as
+// they are, and other encodings give an error.
+func decodeBody(resp *http.Response) error {
+	if resp.Uncompressed {
+		return nil
+	}
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+	default:
+		return fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err == io.EOF {
+		// An empty body has nothing to decompress
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading gzip body: %w", err)
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// errRobotsDisallowed is returned for URLs that robots.txt forbids scraping
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// StatusError is returned for a page served with a status code outside 2xx
+type StatusError struct {
+	StatusCode int
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// scrapeURL fetches a single page and extracts its media data
+func (s *Scraper) scrapeURL(ctx context.Context, url string, parser Parser) (MediaData, error) {
+	if !s.IgnoreRobots && !s.robotsAllowed(ctx, url) {
+		s.log().Info("Skipping URL disallowed by robots.txt", "url", url)
+		return MediaData{}, errRobotsDisallowed
+	}
+
+	// Wait our turn so a single host isn't flooded with requests
+	if err := s.waitForHost(ctx, url); err != nil {
+		return MediaData{}, err
+	}
+	if err := s.waitCrawlDelay(ctx, url); err != nil {
+		return MediaData{}, err
+	}
+
+	// Only fetch the page again if it changed since it was cached
+	var cached cacheEntry
+	header := make(http.Header)
+	isCached := false
+	if s.Cache != nil {
+		if cached, isCached = s.Cache.get(url); isCached {
+			header = cached.conditionalHeaders()
+		}
+	}
+	// Ask for a compressed page unless the headers already name encodings
+	if s.Headers.Get("Accept-Encoding") == "" {
+		header.Set("Accept-Encoding", "gzip")
+	}
+
+	s.log().Info("Scraping URL", "url", url)
+	fetchStart := time.Now()
+	resp, err := s.makeRequestHeaders(ctx, url, header)
+	if errors.Is(err, ErrCircuitOpen) {
+		s.log().Info("Skipping URL of failing host", "url", url)
+		return MediaData{}, err
+	}
+	if err != nil {
+		s.log().Error("Error requesting URL", "url", url, "err", err, "category", Categorize(err))
+		if s.HAR != nil {
+			s.HAR.recordError(url, fetchStart, err)
+		}
+		return MediaData{}, err
+	}
+	// Parsers aren't guaranteed to close the body, and an unclosed body
+	// keeps its connection busy
+	defer closeBody(resp)
+	fetchDuration := time.Since(fetchStart)
+
+	// Count the body bytes the parser reads, and archive the exchange once
+	// it is done
+	counter := &countingReader{r: resp.Body}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{counter, resp.Body}
+	if s.HAR != nil {
+		defer func() {
+			s.HAR.record(url, fetchStart, fetchDuration, time.Since(fetchStart), resp, counter.n)
+		}()
+	}
+
+	if isCached && resp.StatusCode == http.StatusNotModified {
+		s.log().Info("Page unchanged, using cached data", "url", url)
+		data := cached.Data
+		data.Unchanged = true
+		data.FetchDuration = fetchDuration
+		data.ParseDuration = 0
+		return data, nil
+	}
+
+	// Error pages rarely show the site's real content, so they fail like
+	// any other request unless asked for
+	if (resp.StatusCode < 200 || resp.StatusCode > 299) && !s.ParseErrorPages {
+		err := &StatusError{StatusCode: resp.StatusCode}
+		s.log().Error("Error status for URL", "url", url, "status", resp.StatusCode, "category", Categorize(err))
+		return MediaData{}, err
+	}
+
+	// Don't download pages that announce they are too big to be worth it
+	if s.MaxContentLength > 0 && resp.ContentLength > s.MaxContentLength {
+		s.log().Info("Skipping oversized page", "url", url, "content_length", resp.ContentLength, "limit", s.MaxContentLength)
+		data := unparsedMediaData(resp, responseURL(resp, nil))
+		data.FetchDuration = fetchDuration
+		return data, nil
+	}
+
+	// Decompress the page for the parser, keeping the Content-Length that
+	// was sent for the results
+	contentLength := resp.ContentLength
+	if err := decodeBody(resp); err != nil {
+		s.log().Error("Error decoding page", "url", url, "err", err, "category", CategoryParseError)
+		return MediaData{}, &ParseError{Err: err}
+	}
+
+	// Only the start of a huge page is handed to the parser
+	limited := &truncatingReader{r: resp.Body, remaining: s.maxPageSize()}
+	if limited.remaining > 0 {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{limited, resp.Body}
+	}
+
+	parseStart := time.Now()
+	data, err := parser.GetMediaData(resp)
+	if err != nil {
+		s.log().Error("Error parsing media data", "url", url, "err", err, "category", CategoryParseError)
+		return MediaData{}, &ParseError{Err: err}
+	}
+	if limited.truncated {
+		s.log().Warn("Page body truncated", "url", url, "limit", s.maxPageSize())
+		data.Truncated = true
+	}
+	data.FetchDuration = fetchDuration
+	data.ParseDuration = time.Since(parseStart)
+	data.ContentLength = contentLength
+	data.BodySize = counter.n
+
+	// Custom parsers may not know which URL was originally requested
+	if data.RequestedURL == "" {
+		data.RequestedURL = url
+	}
+	if s.Cache != nil {
+		s.Cache.store(url, resp, data)
+	}
+	return data, nil
+}
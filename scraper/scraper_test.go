@@ -0,0 +1,1238 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the scrape logs out of the test output and the retries quick
+	Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	DefaultRetryDelay = time.Millisecond
+	os.Exit(m.Run())
+}
+
+func TestMakeRequestInvalidURL(t *testing.T) {
+	resp, err := makeRequest("http://[::1")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("makeRequest of an invalid URL returned no error")
+	}
+}
+
+func TestUserAgentVaries(t *testing.T) {
+	s := &Scraper{}
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	// Pick from several goroutines at once, as the workers do
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				agent := s.userAgent()
+				mu.Lock()
+				seen[agent] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) < 2 {
+		t.Errorf("1000 calls gave %d distinct User-Agents, want more than one", len(seen))
+	}
+}
+
+// pageServer starts a server answering every request with a small HTML page
+// after calling handle, when it isn't nil
+func pageServer(t *testing.T, handle func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handle != nil {
+			handle(r)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="/img%s.png"></body></html>`, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// pageURLs returns n distinct page URLs on server
+func pageURLs(server *httptest.Server, n int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s/page/%d", server.URL, i)
+	}
+	return urls
+}
+
+func TestScrapeBoundsGoroutines(t *testing.T) {
+	const concurrency = 4
+	var mu sync.Mutex
+	inFlight, maxInFlight, maxGoroutines := 0, 0, 0
+	server := pageServer(t, func(r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		maxGoroutines = max(maxGoroutines, runtime.NumGoroutine())
+		mu.Unlock()
+		time.Sleep(2 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+	before := runtime.NumGoroutine()
+
+	results, errs := ScrapeImages(pageURLs(server, 200), DefaultParser{}, concurrency)
+	if len(results) != 200 || len(errs) != 0 {
+		t.Fatalf("ScrapeImages gave %d results and %d errors, want 200 and 0", len(results), len(errs))
+	}
+	if maxInFlight > concurrency {
+		t.Errorf("%d requests were in flight at once, want at most %d", maxInFlight, concurrency)
+	}
+	// Each connection adds a few goroutines on both ends, but nothing like
+	// one per URL
+	if grown := maxGoroutines - before; grown > 10*concurrency {
+		t.Errorf("goroutines grew by %d during the scrape, want at most %d", grown, 10*concurrency)
+	}
+}
+
+func TestScrapeImagesContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The fourth page cancels the scrape and hangs until its request is aborted
+	var mu sync.Mutex
+	served := 0
+	server := pageServer(t, func(r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			return
+		}
+		mu.Lock()
+		served++
+		hang := served > 3
+		mu.Unlock()
+		if hang {
+			cancel()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		}
+	})
+
+	start := time.Now()
+	results, errs := ScrapeImagesContext(ctx, pageURLs(server, 50), DefaultParser{}, 1)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ScrapeImagesContext took %v after being cancelled", elapsed)
+	}
+	if len(results) != 3 {
+		t.Errorf("got %d results, want the 3 pages scraped before cancelling", len(results))
+	}
+	for url, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s failed with %v, want context.Canceled", url, err)
+		}
+	}
+}
+
+func TestScrapeRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/moved", http.StatusMovedPermanently))
+	mux.Handle("/moved", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><img src="img/new.png"></body></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s := &Scraper{}
+	res, err := s.scrapeURL(context.Background(), server.URL+"/old", DefaultParser{})
+	if err != nil {
+		t.Fatalf("scrapeURL: %v", err)
+	}
+	if res.RequestedURL != server.URL+"/old" {
+		t.Errorf("RequestedURL = %q, want %q", res.RequestedURL, server.URL+"/old")
+	}
+	if res.FinalURL != server.URL+"/new" || res.URL != res.FinalURL {
+		t.Errorf("FinalURL = %q and URL = %q, want both %q", res.FinalURL, res.URL, server.URL+"/new")
+	}
+	// Relative images resolve against the page they were served from
+	if want := []string{server.URL + "/img/new.png"}; !slices.Equal(res.ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", res.ImageURLs, want)
+	}
+
+	s = &Scraper{MaxRedirects: 1}
+	if _, err := s.scrapeURL(context.Background(), server.URL+"/old", DefaultParser{}); !errors.Is(err, errTooManyRedirects) {
+		t.Errorf("with MaxRedirects 1 the error is %v, want errTooManyRedirects", err)
+	}
+	s = &Scraper{MaxRedirects: -1}
+	if _, err := s.scrapeURL(context.Background(), server.URL+"/old", DefaultParser{}); !errors.Is(err, errTooManyRedirects) {
+		t.Errorf("with MaxRedirects -1 the error is %v, want errTooManyRedirects", err)
+	}
+}
+
+func TestScrapeReportsErrors(t *testing.T) {
+	server := pageServer(t, nil)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	good := pageURLs(server, 2)
+	bad := []string{closed.URL + "/down", "http://[::1"}
+	results, errs := ScrapeImages(append(append([]string{}, good...), bad...), DefaultParser{}, 2)
+
+	if len(results) != len(good) {
+		t.Errorf("got %d results, want %d", len(results), len(good))
+	}
+	for _, res := range results {
+		if !slices.Contains(good, res.URL) {
+			t.Errorf("unexpected result for %s", res.URL)
+		}
+	}
+	if len(errs) != len(bad) {
+		t.Errorf("got errors %v, want one for each of %q", errs, bad)
+	}
+	for _, url := range bad {
+		if errs[url] == nil {
+			t.Errorf("no error reported for %s", url)
+		}
+	}
+}
+
+func TestCustomHeaders(t *testing.T) {
+	var mu sync.Mutex
+	var got []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		mu.Unlock()
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123"})
+		}
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scraper{
+		Headers: http.Header{"Accept-Language": {"fr-FR"}, "Referer": {"https://example.com/"}},
+		Jar:     jar,
+	}
+	for _, path := range []string{"/login", "/page"} {
+		resp, err := s.makeRequest(context.Background(), server.URL+path)
+		if err != nil {
+			t.Fatalf("makeRequest: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	for i, header := range got {
+		if header.Get("Accept-Language") != "fr-FR" || header.Get("Referer") != "https://example.com/" {
+			t.Errorf("request %d headers = %v, want the custom headers", i+1, header)
+		}
+		if !slices.Contains(DefaultUserAgents, header.Get("User-Agent")) {
+			t.Errorf("request %d User-Agent = %q, want one of DefaultUserAgents", i+1, header.Get("User-Agent"))
+		}
+	}
+	if cookie := got[1].Get("Cookie"); cookie != "session=abc123" {
+		t.Errorf("second request Cookie = %q, want the one the server set", cookie)
+	}
+
+	// A User-Agent among the headers replaces the random one
+	s.Headers.Set("User-Agent", "custom-agent/1.0")
+	resp, err := s.makeRequest(context.Background(), server.URL+"/page")
+	if err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	resp.Body.Close()
+	if agent := got[2].Get("User-Agent"); agent != "custom-agent/1.0" {
+		t.Errorf("User-Agent = %q, want the custom one", agent)
+	}
+}
+
+// slowServer starts a server that waits delay, or until the request is
+// aborted, before answering
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	return pageServer(t, func(r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := slowServer(t, time.Second)
+
+	tests := []struct {
+		name string
+		s    *Scraper
+	}{
+		{"Timeout", &Scraper{Timeout: 20 * time.Millisecond}},
+		{"HeaderTimeout", &Scraper{HeaderTimeout: 20 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		resp, err := tt.s.makeRequest(context.Background(), server.URL)
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("with %s the request to a slow server succeeded", tt.name)
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Errorf("with %s the error is %v, want a timeout", tt.name, err)
+		}
+		// Each of the attempts gives up long before the server answers
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("with %s the request took %v, want it cut short", tt.name, elapsed)
+		}
+	}
+}
+
+func TestHTTPClientShared(t *testing.T) {
+	s := &Scraper{}
+	if s.httpClient() != s.httpClient() {
+		t.Error("httpClient built a new client on the second call")
+	}
+}
+
+// benchmarkRequests fetches a small page b.N times, with a new scraper for
+// each request when fresh is set and a shared one otherwise
+func benchmarkRequests(b *testing.B, fresh bool) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer server.Close()
+
+	shared := &Scraper{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := shared
+		if fresh {
+			s = &Scraper{}
+		}
+		resp, err := s.httpClient().Get(server.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if fresh {
+			s.httpClient().CloseIdleConnections()
+		}
+	}
+}
+
+func BenchmarkSharedClient(b *testing.B) {
+	benchmarkRequests(b, false)
+}
+
+func BenchmarkClientPerRequest(b *testing.B) {
+	// What every request used to do: a new client and transport, so no
+	// connection is ever reused
+	benchmarkRequests(b, true)
+}
+
+func TestTransportTuning(t *testing.T) {
+	transport := (&Scraper{}).newTransport()
+	if transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || transport.IdleConnTimeout != DefaultIdleConnTimeout ||
+		transport.MaxIdleConns != 0 || transport.MaxConnsPerHost != 0 {
+		t.Errorf("default transport pools %d per host of %d, closing idle after %v, %d per host at once",
+			transport.MaxIdleConnsPerHost, transport.MaxIdleConns, transport.IdleConnTimeout, transport.MaxConnsPerHost)
+	}
+
+	s := &Scraper{MaxIdleConns: 10, MaxIdleConnsPerHost: 3, IdleConnTimeout: time.Second, MaxConnsPerHost: 4}
+	transport = s.newTransport()
+	if transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 3 || transport.IdleConnTimeout != time.Second || transport.MaxConnsPerHost != 4 {
+		t.Errorf("tuned transport = %d idle, %d idle per host, %v idle timeout, %d per host",
+			transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout, transport.MaxConnsPerHost)
+	}
+}
+
+// benchmarkHosts scrapes pages spread over several local servers, standing
+// in for separate hosts, with 16 workers and idlePerHost pooled connections
+// for each
+func benchmarkHosts(b *testing.B, idlePerHost int) {
+	const hosts, pagesPerHost = 4, 16
+	var urls []string
+	for i := 0; i < hosts; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><img src="/a.png"></body></html>`)
+		}))
+		defer server.Close()
+		for j := 0; j < pagesPerHost; j++ {
+			urls = append(urls, fmt.Sprintf("%s/page/%d", server.URL, j))
+		}
+	}
+
+	s := &Scraper{Concurrency: 16, MaxIdleConnsPerHost: idlePerHost, IgnoreRobots: true}
+	defer s.httpClient().CloseIdleConnections()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, errs := s.Scrape(urls); len(errs) > 0 {
+			b.Fatal(errs)
+		}
+	}
+}
+
+func BenchmarkIdleConnsPerHost2(b *testing.B) {
+	// net/http's default keeps only two idle connections per host, so most
+	// of the 16 workers dial a new connection for every page
+	benchmarkHosts(b, 2)
+}
+
+func BenchmarkIdleConnsPerHostDefault(b *testing.B) {
+	benchmarkHosts(b, 0)
+}
+
+// collectWorkers is how many goroutines the collection benchmarks run, as
+// many as a high -concurrency scrape
+const collectWorkers = 64
+
+// benchmarkCollect gathers b.N results with collect, which is handed a
+// produce function sending them from collectWorkers goroutines
+func benchmarkCollect(b *testing.B, collect func(produce func(send func(PageResult))) int) {
+	produce := func(send func(PageResult)) {
+		var wg sync.WaitGroup
+		for w := 0; w < collectWorkers; w++ {
+			// Spread b.N over the workers, the first ones taking the remainder
+			n := b.N / collectWorkers
+			if w < b.N%collectWorkers {
+				n++
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					send(PageResult{Data: MediaData{StatusCode: http.StatusOK}})
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	b.ResetTimer()
+	if n := collect(produce); n != b.N {
+		b.Fatalf("collected %d results, want %d", n, b.N)
+	}
+}
+
+func BenchmarkCollectMutex(b *testing.B) {
+	// How results used to be gathered: every worker appends under one lock
+	benchmarkCollect(b, func(produce func(send func(PageResult))) int {
+		var mu sync.Mutex
+		var results []MediaData
+		produce(func(res PageResult) {
+			mu.Lock()
+			results = append(results, res.Data)
+			mu.Unlock()
+		})
+		return len(results)
+	})
+}
+
+func BenchmarkCollectChannel(b *testing.B) {
+	// What scrape does: workers send on a channel read by a single collector
+	benchmarkCollect(b, func(produce func(send func(PageResult))) int {
+		out := make(chan PageResult)
+		go func() {
+			produce(func(res PageResult) { out <- res })
+			close(out)
+		}()
+		var results []MediaData
+		for res := range out {
+			results = append(results, res.Data)
+		}
+		return len(results)
+	})
+}
+
+// titleParser is a custom Parser recording only the page titles
+type titleParser struct{}
+
+func (titleParser) GetMediaData(resp *http.Response) (MediaData, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return MediaData{}, err
+	}
+	return MediaData{URL: resp.Request.URL.String(), Title: fmt.Sprintf("%d bytes", len(body))}, nil
+}
+
+func TestScraperSettings(t *testing.T) {
+	var mu sync.Mutex
+	agents := make(map[string]bool)
+	server := pageServer(t, func(r *http.Request) {
+		mu.Lock()
+		agents[r.Header.Get("User-Agent")] = true
+		mu.Unlock()
+	})
+	files := serveFiles(t, map[string]string{"/sitemap.xml": urlset(pageURLs(server, 3)...)})
+
+	var logs bytes.Buffer
+	s := &Scraper{
+		Parser:       titleParser{},
+		Concurrency:  2,
+		UserAgents:   []string{"test-agent/1.0"},
+		Logger:       slog.New(slog.NewTextHandler(&logs, nil)),
+		IgnoreRobots: true,
+	}
+	results, errs, err := s.ScrapeSitemap(files.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ScrapeSitemap: %v", err)
+	}
+	if len(results) != 3 || len(errs) != 0 {
+		t.Fatalf("ScrapeSitemap gave %d results and errors %v, want 3 and none", len(results), errs)
+	}
+	for _, res := range results {
+		if !strings.HasSuffix(res.Title, " bytes") {
+			t.Errorf("result %+v wasn't made by the scraper's parser", res)
+		}
+	}
+	if len(agents) != 1 || !agents["test-agent/1.0"] {
+		t.Errorf("User-Agents sent = %v, want just the scraper's", agents)
+	}
+	if !strings.Contains(logs.String(), "Scraping URL") {
+		t.Errorf("scraper's Logger got %q, want the progress messages", logs.String())
+	}
+
+	// Another scraper keeps its own settings
+	results, _ = (&Scraper{IgnoreRobots: true}).Scrape(pageURLs(server, 1))
+	if len(results) != 1 || len(results[0].ImageURLs) != 1 {
+		t.Errorf("default scraper results = %+v, want the DefaultParser's", results)
+	}
+
+	if _, errs, err := s.ScrapeSitemap(files.URL + "/missing.xml"); err == nil || errs != nil {
+		t.Errorf("ScrapeSitemap of a missing sitemap = %v, %v, want only an error", errs, err)
+	}
+}
+
+func TestCustomUserAgent(t *testing.T) {
+	s := &Scraper{UserAgents: []string{"only-agent/1.0"}}
+	for i := 0; i < 100; i++ {
+		if agent := s.userAgent(); agent != "only-agent/1.0" {
+			t.Fatalf("userAgent() = %q, want the only custom agent", agent)
+		}
+	}
+	if agent := (&Scraper{UserAgents: []string{}}).userAgent(); !slices.Contains(DefaultUserAgents, agent) {
+		t.Errorf("userAgent() with an empty list = %q, want one of DefaultUserAgents", agent)
+	}
+}
+
+func TestUserAgentRotation(t *testing.T) {
+	agents := []string{"agent/1", "agent/2", "agent/3"}
+
+	s := &Scraper{UserAgents: agents, UserAgentRotation: "round-robin"}
+	var got []string
+	for i := 0; i < 2*len(agents); i++ {
+		got = append(got, s.userAgent())
+	}
+	want := append(slices.Clone(agents), agents...)
+	if !slices.Equal(got, want) {
+		t.Errorf("round-robin agents = %q, want %q", got, want)
+	}
+
+	s = &Scraper{UserAgents: agents, UserAgentRotation: "fixed"}
+	for i := 0; i < 10; i++ {
+		if agent := s.userAgent(); agent != "agent/1" {
+			t.Fatalf("fixed userAgent() = %q, want %q", agent, "agent/1")
+		}
+	}
+
+	// Every request of a scrape takes the next agent
+	var mu sync.Mutex
+	var sent []string
+	server := pageServer(t, func(r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.UserAgent())
+		mu.Unlock()
+	})
+	s = &Scraper{UserAgents: agents, UserAgentRotation: "round-robin", Concurrency: 1, IgnoreRobots: true}
+	if _, errs := s.Scrape(pageURLs(server, 6)); len(errs) > 0 {
+		t.Fatalf("Scrape errors: %v", errs)
+	}
+	if !slices.Equal(sent, want) {
+		t.Errorf("requests sent agents %q, want %q", sent, want)
+	}
+}
+
+func TestStartJitter(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := pageServer(t, func(r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+	})
+
+	s := &Scraper{Concurrency: 20, StartJitter: 200 * time.Millisecond, IgnoreRobots: true}
+	if _, errs := s.Scrape(pageURLs(server, 20)); len(errs) > 0 {
+		t.Fatalf("Scrape errors: %v", errs)
+	}
+	first, last := arrivals[0], arrivals[0]
+	for _, at := range arrivals {
+		if at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	if spread := last.Sub(first); spread < 50*time.Millisecond {
+		t.Errorf("20 workers' first requests arrived within %v, want them spread out", spread)
+	}
+
+	// A cancelled scrape doesn't sit out the jitter
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	(&Scraper{StartJitter: time.Hour}).waitStartJitter(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waitStartJitter after cancel took %v", elapsed)
+	}
+}
+
+func TestScrapeDeadline(t *testing.T) {
+	server := slowServer(t, 50*time.Millisecond)
+	urls := pageURLs(server, 40)
+
+	const deadline = 250 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+	start := time.Now()
+	// The per-request timeout is far longer than any page takes, so only
+	// the deadline stops the scrape
+	s := &Scraper{Concurrency: 2, Timeout: time.Minute, IgnoreRobots: true}
+	results, errs := s.ScrapeContext(ctx, urls)
+	elapsed := time.Since(start)
+
+	if elapsed > deadline+200*time.Millisecond {
+		t.Errorf("scrape took %v, want it to stop near the %v deadline", elapsed, deadline)
+	}
+	if len(results) == 0 || len(results) >= len(urls) {
+		t.Errorf("scrape gave %d of %d pages, want the partial results from before the deadline", len(results), len(urls))
+	}
+	for url, err := range errs {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s failed with %v, want the deadline", url, err)
+		}
+	}
+}
+
+func TestScrapeStream(t *testing.T) {
+	server := pageServer(t, nil)
+	urls := append(pageURLs(server, 25), "http://[::1")
+
+	var failed []string
+	s := &Scraper{Concurrency: 4, IgnoreRobots: true}
+	seen := make(map[string]bool)
+	for res := range s.ScrapeStream(context.Background(), urls, func(url string, err error) {
+		failed = append(failed, url)
+	}) {
+		if seen[res.URL] {
+			t.Errorf("%s was sent twice", res.URL)
+		}
+		seen[res.URL] = true
+	}
+	if len(seen) != 25 {
+		t.Errorf("channel gave %d pages, want 25", len(seen))
+	}
+	if !slices.Equal(failed, []string{"http://[::1"}) {
+		t.Errorf("onError got %q, want the invalid URL", failed)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxy is sent the whole URL of the page it should fetch
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><img src="/proxied.png"></body></html>`)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Scraper{Proxy: proxyURL, IgnoreRobots: true}
+	results, errs := s.Scrape([]string{"http://site.invalid/page"})
+	if len(results) != 1 {
+		t.Fatalf("Scrape gave errors %v, want the page through the proxy", errs)
+	}
+	if want := []string{"http://site.invalid/proxied.png"}; !slices.Equal(results[0].ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", results[0].ImageURLs, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(proxied, []string{"http://site.invalid/page"}) {
+		t.Errorf("proxy got requests for %q, want just the page", proxied)
+	}
+}
+
+func TestScrapeTimings(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	server := slowServer(t, delay)
+
+	results, errs := (&Scraper{IgnoreRobots: true}).Scrape(pageURLs(server, 1))
+	if len(results) != 1 {
+		t.Fatalf("Scrape errors = %v", errs)
+	}
+	res := results[0]
+	if res.FetchDuration < delay {
+		t.Errorf("FetchDuration = %v, want at least the server's %v", res.FetchDuration, delay)
+	}
+	if res.ParseDuration <= 0 {
+		t.Errorf("ParseDuration = %v, want it positive", res.ParseDuration)
+	}
+	if want := int64(len(`<html><body><img src="/img/page/0.png"></body></html>`)); res.ContentLength != want {
+		t.Errorf("ContentLength = %d, want %d", res.ContentLength, want)
+	}
+}
+
+// lazyParser reads a little of each page and neither finishes nor closes
+// its body, or fails outright when fail is set
+type lazyParser struct{ fail bool }
+
+func (p lazyParser) GetMediaData(resp *http.Response) (MediaData, error) {
+	if p.fail {
+		return MediaData{}, errors.New("parser failed")
+	}
+	io.ReadFull(resp.Body, make([]byte, 8))
+	return MediaData{URL: resp.Request.URL.String()}, nil
+}
+
+func TestScrapeReusesConnections(t *testing.T) {
+	const concurrency = 4
+	// The transport returns a drained connection to the pool asynchronously,
+	// so a worker may dial again before an idle one is put back
+	const maxConnections = 2 * concurrency
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", strings.Repeat("<p>filler</p>", 200))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	// The bodies the parsers leave behind must still be closed, whether they
+	// succeed or fail, so their connections go back to the pool
+	for _, parser := range []Parser{lazyParser{}, lazyParser{fail: true}} {
+		connections.Store(0)
+		s := &Scraper{Parser: parser, Concurrency: concurrency, IgnoreRobots: true}
+		s.Scrape(pageURLs(server, 100))
+		if got := connections.Load(); got > maxConnections {
+			t.Errorf("%+v: 100 pages opened %d connections, want at most %d", parser, got, maxConnections)
+		}
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "editor" || pass != "s3cret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="staging"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body></body></html>")
+	}))
+	defer server.Close()
+	page := server.URL + "/page"
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tests := []struct {
+		name, user, pass string
+		status           int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "editor", "wrong", http.StatusUnauthorized},
+		{"right credentials", "editor", "s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		s := &Scraper{Username: tt.user, Password: tt.pass, Logger: logger, IgnoreRobots: true}
+		results, errs := s.Scrape([]string{page})
+		var status *StatusError
+		switch {
+		case tt.status == http.StatusOK && len(results) != 1:
+			t.Errorf("%s: Scrape errors = %v, want the page", tt.name, errs)
+		case tt.status != http.StatusOK && (!errors.As(errs[page], &status) || status.StatusCode != tt.status):
+			t.Errorf("%s: Scrape error = %v, want status %d", tt.name, errs[page], tt.status)
+		}
+	}
+	if strings.Contains(logs.String(), "s3cret") {
+		t.Errorf("the password was logged:\n%s", logs.String())
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	var mu sync.Mutex
+	var auth []string
+	server := pageServer(t, func(r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+	})
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s := &Scraper{
+		BearerToken:  "eyJhbGciOi.token",
+		Headers:      http.Header{"Authorization": {"Basic ignored"}},
+		Logger:       logger,
+		IgnoreRobots: true,
+	}
+	if _, errs := s.Scrape(pageURLs(server, 3)); len(errs) > 0 {
+		t.Fatalf("Scrape errors: %v", errs)
+	}
+	want := []string{"Bearer eyJhbGciOi.token", "Bearer eyJhbGciOi.token", "Bearer eyJhbGciOi.token"}
+	if !slices.Equal(auth, want) {
+		t.Errorf("Authorization headers = %q, want %q", auth, want)
+	}
+	if strings.Contains(logs.String(), "eyJhbGciOi") {
+		t.Errorf("the token was logged:\n%s", logs.String())
+	}
+}
+
+// recordingTransport answers every request with a small page and keeps the
+// requests it was sent
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.requests = append(rt.requests, req)
+	rt.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       io.NopCloser(strings.NewReader(`<html><body><img src="/a.png"></body></html>`)),
+		Request:    req,
+	}, nil
+}
+
+func TestFakeTransport(t *testing.T) {
+	transport := &recordingTransport{}
+	s := &Scraper{Transport: transport, IgnoreRobots: true}
+	results, errs := s.Scrape([]string{"https://example.invalid/page"})
+	if len(errs) != 0 || len(results) != 1 {
+		t.Fatalf("Scrape = %+v, %v, want one page", results, errs)
+	}
+	if want := []string{"https://example.invalid/a.png"}; !slices.Equal(results[0].ImageURLs, want) {
+		t.Errorf("ImageURLs = %q, want %q", results[0].ImageURLs, want)
+	}
+	if len(transport.requests) != 1 {
+		t.Fatalf("transport got %d requests, want 1", len(transport.requests))
+	}
+	if agent := transport.requests[0].UserAgent(); !slices.Contains(DefaultUserAgents, agent) {
+		t.Errorf("User-Agent = %q, want one of DefaultUserAgents", agent)
+	}
+}
+
+// tlsPageServer starts an HTTPS server offering HTTP/2 that serves a page
+// and records the protocol of each request, returning a TLS config that
+// trusts it
+func tlsPageServer(t *testing.T) (*httptest.Server, *tls.Config, *atomic.Value) {
+	t.Helper()
+	var proto atomic.Value
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto.Store(r.Proto)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><img src="/a.png"></body></html>`)
+	}))
+	server.EnableHTTP2 = true
+	// Rejected handshakes are expected, so keep them out of the test output
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	return server, &tls.Config{RootCAs: roots}, &proto
+}
+
+func TestHTTPProtocol(t *testing.T) {
+	server, trust, proto := tlsPageServer(t)
+	for _, tt := range []struct {
+		forceHTTP1 bool
+		want       string
+	}{
+		{false, "HTTP/2.0"},
+		{true, "HTTP/1.1"},
+	} {
+		s := &Scraper{TLSConfig: trust, ForceHTTP1: tt.forceHTTP1, IgnoreRobots: true}
+		if _, errs := s.Scrape([]string{server.URL + "/page"}); len(errs) > 0 {
+			t.Fatalf("ForceHTTP1 %v: Scrape errors: %v", tt.forceHTTP1, errs)
+		}
+		if got := proto.Load(); got != tt.want {
+			t.Errorf("ForceHTTP1 %v: request used %v, want %s", tt.forceHTTP1, got, tt.want)
+		}
+	}
+}
+
+func TestSelfSignedTLS(t *testing.T) {
+	server, trust, _ := tlsPageServer(t)
+	page := server.URL + "/page"
+	tests := []struct {
+		name   string
+		config *tls.Config
+		ok     bool
+	}{
+		{"system roots", nil, false},
+		{"InsecureSkipVerify", &tls.Config{InsecureSkipVerify: true}, true},
+		{"trusted CA", trust, true},
+	}
+	for _, tt := range tests {
+		s := &Scraper{TLSConfig: tt.config, IgnoreRobots: true}
+		results, errs := s.Scrape([]string{page})
+		if tt.ok && len(results) != 1 {
+			t.Errorf("%s: Scrape errors = %v, want the page", tt.name, errs)
+		}
+		var unknownAuthority x509.UnknownAuthorityError
+		if !tt.ok && !errors.As(errs[page], &unknownAuthority) {
+			t.Errorf("%s: Scrape error = %v, want an unknown authority error", tt.name, errs[page])
+		}
+	}
+}
+
+func TestBoundConcurrency(t *testing.T) {
+	tests := []struct {
+		concurrency, max, want int
+	}{
+		{0, 0, DefaultConcurrency},
+		{-5, 0, DefaultConcurrency},
+		{7, 0, 7},
+		{DefaultMaxConcurrency + 1, 0, DefaultMaxConcurrency},
+		{50, 8, 8},
+		{DefaultConcurrency * 100, 8, 8},
+	}
+	for _, tt := range tests {
+		s := &Scraper{MaxConcurrency: tt.max}
+		if got := s.boundConcurrency(tt.concurrency); got != tt.want {
+			t.Errorf("boundConcurrency(%d) with MaxConcurrency %d = %d, want %d", tt.concurrency, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestScrapeZeroConcurrency(t *testing.T) {
+	server := pageServer(t, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		results, errs := ScrapeImages(pageURLs(server, 10), DefaultParser{}, 0)
+		if len(results) != 10 || len(errs) != 0 {
+			t.Errorf("ScrapeImages with concurrency 0 gave %d results and errors %v, want 10 and none", len(results), errs)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("ScrapeImages with concurrency 0 didn't finish")
+	}
+}
+
+func TestScrapeProgress(t *testing.T) {
+	server := pageServer(t, nil)
+	urls := append(pageURLs(server, 9), "http://[::1")
+
+	var calls [][2]int
+	s := &Scraper{Concurrency: 4, IgnoreRobots: true}
+	s.Progress = func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	}
+	s.Scrape(urls)
+
+	// Failed URLs count too, and the calls come one at a time in order
+	if len(calls) != len(urls) {
+		t.Fatalf("Progress was called %d times, want %d", len(calls), len(urls))
+	}
+	for i, call := range calls {
+		if call != [2]int{i + 1, len(urls)} {
+			t.Errorf("call %d was Progress(%d, %d), want Progress(%d, %d)", i+1, call[0], call[1], i+1, len(urls))
+		}
+	}
+}
+
+func TestMaxPageSize(t *testing.T) {
+	filler := strings.Repeat("<p>filler</p>", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="/first.png">%s<img src="/last.png"></body></html>`, filler)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		maxPageSize int64
+		truncated   bool
+		images      int
+	}{
+		{1000, true, 1},
+		{0, false, 2},
+		{-1, false, 2},
+	}
+	for _, tt := range tests {
+		s := &Scraper{MaxPageSize: tt.maxPageSize, IgnoreRobots: true}
+		results, errs := s.Scrape([]string{server.URL + "/page"})
+		if len(results) != 1 {
+			t.Fatalf("MaxPageSize %d: Scrape errors = %v", tt.maxPageSize, errs)
+		}
+		res := results[0]
+		if res.Truncated != tt.truncated || len(res.ImageURLs) != tt.images {
+			t.Errorf("MaxPageSize %d: Truncated = %v with %d images, want %v with %d",
+				tt.maxPageSize, res.Truncated, len(res.ImageURLs), tt.truncated, tt.images)
+		}
+	}
+}
+
+func TestMaxContentLength(t *testing.T) {
+	page := `<html><body><img src="/a.png">` + strings.Repeat("<p>filler</p>", 100) + `</body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/huge":
+			// Only the headers are read, so the body needn't be that big
+			w.Header().Set("Content-Length", "1073741824")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, page)
+		case "/unknown":
+			// Flushing before the end leaves the length unannounced
+			fmt.Fprint(w, page[:10])
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, page[10:])
+		default:
+			fmt.Fprint(w, page)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path          string
+		skipped       bool
+		contentLength int64
+	}{
+		{"/huge", true, 1 << 30},
+		{"/unknown", false, -1},
+		{"/small", false, int64(len(page))},
+	}
+	s := &Scraper{MaxContentLength: int64(len(page)), IgnoreRobots: true}
+	for _, tt := range tests {
+		results, errs := s.Scrape([]string{server.URL + tt.path})
+		if len(results) != 1 {
+			t.Fatalf("%s: Scrape errors = %v", tt.path, errs)
+		}
+		res := results[0]
+		if res.Skipped != tt.skipped || res.ContentLength != tt.contentLength {
+			t.Errorf("%s: Skipped = %v with ContentLength %d, want %v with %d",
+				tt.path, res.Skipped, res.ContentLength, tt.skipped, tt.contentLength)
+		}
+		if !tt.skipped && (len(res.ImageURLs) != 1 || res.BodySize != int64(len(page))) {
+			t.Errorf("%s: %d images from %d bytes, want 1 from %d", tt.path, len(res.ImageURLs), res.BodySize, len(page))
+		}
+		if tt.skipped && len(res.ImageURLs) != 0 {
+			t.Errorf("%s: skipped page has images %q", tt.path, res.ImageURLs)
+		}
+	}
+}
+
+func TestGzipPage(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	fmt.Fprint(zw, `<html><head><title>Zipped</title></head><body><img src="/a.png">`+strings.Repeat("<p>filler</p>", 100)+`</body></html>`)
+	zw.Close()
+
+	var acceptEncoding atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding.Store(r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/brotli" {
+			w.Header().Set("Content-Encoding", "br")
+			fmt.Fprint(w, "not really brotli")
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	// The scraper asks for gzip itself unless the headers already do
+	for _, headers := range []http.Header{nil, {"Accept-Encoding": {"gzip"}}} {
+		s := &Scraper{Headers: headers, IgnoreRobots: true}
+		results, errs := s.Scrape([]string{server.URL + "/page"})
+		if len(results) != 1 {
+			t.Fatalf("Accept-Encoding %q: Scrape errors = %v", headers.Get("Accept-Encoding"), errs)
+		}
+		if got := acceptEncoding.Load(); got != "gzip" {
+			t.Errorf("request Accept-Encoding = %q, want gzip", got)
+		}
+		res := results[0]
+		if res.Title != "Zipped" || len(res.ImageURLs) != 1 {
+			t.Errorf("gzip page parsed to title %q with images %q", res.Title, res.ImageURLs)
+		}
+		if res.BodySize != int64(compressed.Len()) {
+			t.Errorf("BodySize = %d, want the %d compressed bytes", res.BodySize, compressed.Len())
+		}
+	}
+
+	_, errs := (&Scraper{IgnoreRobots: true}).Scrape([]string{server.URL + "/brotli"})
+	var parseErr *ParseError
+	if !errors.As(errs[server.URL+"/brotli"], &parseErr) {
+		t.Errorf("Scrape of an unsupported encoding = %v, want a ParseError", errs)
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<html><body><img src="/not-found.png"></body></html>`)
+	}))
+	defer server.Close()
+	pageURL := server.URL + "/gone"
+
+	results, errs := (&Scraper{IgnoreRobots: true}).Scrape([]string{pageURL})
+	if len(results) != 0 {
+		t.Errorf("Scrape of a 404 gave results %+v, want none", results)
+	}
+	var status *StatusError
+	if !errors.As(errs[pageURL], &status) || status.StatusCode != http.StatusNotFound {
+		t.Errorf("Scrape error = %v, want a 404 StatusError", errs[pageURL])
+	}
+
+	results, errs = (&Scraper{IgnoreRobots: true, ParseErrorPages: true}).Scrape([]string{pageURL})
+	if len(errs) != 0 || len(results) != 1 {
+		t.Fatalf("Scrape with ParseErrorPages = %+v, %v, want one result", results, errs)
+	}
+	if res := results[0]; res.StatusCode != http.StatusNotFound || len(res.ImageURLs) != 1 {
+		t.Errorf("parsed error page has status %d and images %q", res.StatusCode, res.ImageURLs)
+	}
+}
+
+func TestTruncatingReader(t *testing.T) {
+	for _, tt := range []struct {
+		body      string
+		truncated bool
+	}{
+		{"12345", false},
+		{"123456", true},
+	} {
+		r := &truncatingReader{r: strings.NewReader(tt.body), remaining: 5}
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != "12345" || r.truncated != tt.truncated {
+			t.Errorf("reading %q gave %q, %v, truncated %v, want %q, truncated %v", tt.body, got, err, r.truncated, "12345", tt.truncated)
+		}
+	}
+}
+
+func TestSitemapToResults(t *testing.T) {
+	files := map[string]string{
+		"/article/1": `<html><head><title>First</title><meta name="description" content="The first article">
+<meta property="og:image" content="/img/1-social.jpg"></head>
+<body><img src="/img/1.jpg" alt="One"><img src="/img/1.jpg"></body></html>`,
+		"/article/2": `<html><head><title>Second</title></head><body><img src="../img/2.png"></body></html>`,
+	}
+	server := serveFiles(t, files)
+	files["/sitemap.xml"] = urlset(server.URL+"/article/1", server.URL+"/article/2", server.URL+"/article/gone")
+
+	s := &Scraper{Concurrency: 2}
+	urls, err := s.ParseSitemap(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+	if len(urls) != 3 {
+		t.Fatalf("ParseSitemap = %q, want 3 URLs", urls)
+	}
+	results, errs := s.Scrape(urls)
+
+	// The missing page fails with its status
+	if len(errs) != 1 {
+		t.Errorf("errors = %v, want just the missing page", errs)
+	}
+	var status *StatusError
+	if err := errs[server.URL+"/article/gone"]; !errors.As(err, &status) || status.StatusCode != http.StatusNotFound {
+		t.Errorf("missing page error = %v, want a 404 StatusError", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].URL < results[j].URL })
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	first, second := results[0], results[1]
+	if first.URL != server.URL+"/article/1" || first.Title != "First" || first.MetaDescription != "The first article" ||
+		first.StatusCode != http.StatusOK {
+		t.Errorf("first page = %+v", first)
+	}
+	wantImages := []string{server.URL + "/img/1.jpg", server.URL + "/img/1-social.jpg"}
+	if !slices.Equal(first.ImageURLs, wantImages) {
+		t.Errorf("first page ImageURLs = %q, want %q", first.ImageURLs, wantImages)
+	}
+	if len(first.Images) != 1 || first.Images[0].Alt != "One" {
+		t.Errorf("first page Images = %+v, want the one img with its alt text", first.Images)
+	}
+	if second.Title != "Second" || !slices.Equal(second.ImageURLs, []string{server.URL + "/img/2.png"}) {
+		t.Errorf("second page = %+v", second)
+	}
+}
+
+func TestParserLogsToScraperLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/notes.txt" {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "not a page")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><script type="application/ld+json">{not json</script></head><body></body></html>`)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s := &Scraper{IgnoreRobots: true, Logger: logger}
+	s.Scrape([]string{server.URL + "/notes.txt", server.URL + "/page"})
+	for _, message := range []string{"Skipping non-HTML response", "Skipping malformed JSON-LD"} {
+		if !strings.Contains(logs.String(), message) {
+			t.Errorf("scraper logger missing %q in:\n%s", message, logs.String())
+		}
+	}
+}
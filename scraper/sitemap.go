@@ -0,0 +1,384 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Sitemap structure to parse XML sitemap data. The tags give no namespace,
+// so elements match by local name whether the document uses the sitemap
+// namespace as its default, binds it to a prefix, or omits it.
+type Sitemap struct {
+	XMLName xml.Name `xml:"urlset"`
+	Urls    []struct {
+		Loc        string `xml:"loc"`
+		LastMod    string `xml:"lastmod"`
+		ChangeFreq string `xml:"changefreq"`
+		Priority   string `xml:"priority"`
+	} `xml:"url"`
+}
+
+// SitemapEntry is a page listed in a sitemap along with the optional hints
+// the sitemap gives about it
+type SitemapEntry struct {
+	Loc string
+	// LastMod is when the page last changed as given in the sitemap, in W3C
+	// datetime format; empty when not given
+	LastMod string
+	// ChangeFreq is how often the page is expected to change, e.g. "daily"
+	ChangeFreq string
+	// Priority is the page's importance relative to the rest of the site,
+	// from 0.0 to 1.0. The sitemap protocol's default of 0.5 is used when
+	// the sitemap gives none.
+	Priority float64
+}
+
+// defaultSitemapPriority is the priority of pages the sitemap gives none for
+const defaultSitemapPriority = 0.5
+
+// lastModFormats are the W3C datetime forms allowed in <lastmod>
+var lastModFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// Modified parses LastMod, returning the zero time when it is missing or
+// malformed
+func (e SitemapEntry) Modified() time.Time {
+	for _, format := range lastModFormats {
+		if t, err := time.Parse(format, strings.TrimSpace(e.LastMod)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// SitemapOrders are the values accepted by SortSitemapEntries
+var SitemapOrders = map[string]bool{"": true, "priority": true, "lastmod": true}
+
+// SortSitemapEntries orders entries by "priority", highest first, or by
+// "lastmod", most recently modified first. Entries that compare equal keep
+// their sitemap order, as do all entries for any other value of by.
+func SortSitemapEntries(entries []SitemapEntry, by string) {
+	switch by {
+	case "priority":
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Priority > entries[j].Priority
+		})
+	case "lastmod":
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Modified().After(entries[j].Modified())
+		})
+	}
+}
+
+// SitemapLocs returns the page URLs of entries
+func SitemapLocs(entries []SitemapEntry) []string {
+	var urls []string
+	for _, entry := range entries {
+		urls = append(urls, entry.Loc)
+	}
+	return urls
+}
+
+// sitemapKey identifies the page at loc for DedupeSitemapEntries. The scheme
+// is ignored, since sites often list both http and https copies, and so is
+// the case of the host. With trimSlash a trailing slash is ignored too.
+func sitemapKey(loc string, trimSlash bool) string {
+	parsed, err := url.Parse(loc)
+	if err != nil || parsed.Host == "" {
+		return loc
+	}
+	// An empty path is the same page as "/"
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if trimSlash {
+		path = strings.TrimSuffix(path, "/")
+	}
+	key := strings.ToLower(parsed.Host) + path
+	if parsed.RawQuery != "" {
+		key += "?" + parsed.RawQuery
+	}
+	return key
+}
+
+// DedupeSitemapEntries drops entries for pages already listed by an earlier
+// entry, keeping the first. Locations are compared ignoring the scheme and
+// the case of the host, and with trimSlash a trailing slash.
+func DedupeSitemapEntries(entries []SitemapEntry, trimSlash bool) []SitemapEntry {
+	seen := make(map[string]bool)
+	var kept []SitemapEntry
+	for _, entry := range entries {
+		key := sitemapKey(entry.Loc, trimSlash)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// SitemapIndex structure to parse a sitemap index, whose entries point to
+// further sitemap files rather than to pages
+type SitemapIndex struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// maxSitemapDepth limits how many levels of nested sitemap indexes are followed
+var maxSitemapDepth = 3
+
+// ParseSitemap parses the XML sitemap and returns the URLs, without repeats.
+// Sitemap indexes are followed recursively up to maxSitemapDepth levels.
+func ParseSitemap(sitemapURL string) ([]string, error) {
+	return DefaultScraper.ParseSitemap(sitemapURL)
+}
+
+// ParseSitemap is like the package-level ParseSitemap but fetches with the
+// scraper's settings
+func (s *Scraper) ParseSitemap(sitemapURL string) ([]string, error) {
+	entries, err := s.ParseSitemapEntries(sitemapURL)
+	return SitemapLocs(entries), err
+}
+
+// ParseSitemapEntries is like ParseSitemap but keeps the lastmod, changefreq
+// and priority given for each page. Pages listed more than once, including
+// under http and https, are only returned once.
+func (s *Scraper) ParseSitemapEntries(sitemapURL string) ([]SitemapEntry, error) {
+	visited := make(map[string]bool)
+	entries, err := s.parseSitemapDepth(sitemapURL, 0, visited)
+	return DedupeSitemapEntries(entries, s.IgnoreTrailingSlash), err
+}
+
+// MergeSitemaps parses every sitemap in sitemapURLs and returns their entries
+// combined and without repeats. A sitemap that fails doesn't stop the rest;
+// its error is returned in the errors map instead.
+func (s *Scraper) MergeSitemaps(sitemapURLs []string) ([]SitemapEntry, map[string]error) {
+	var entries []SitemapEntry
+	errs := make(map[string]error)
+	for _, sitemapURL := range dedupeStrings(sitemapURLs) {
+		found, err := s.ParseSitemapEntries(sitemapURL)
+		if err != nil {
+			s.log().Error("Error parsing sitemap", "url", sitemapURL, "err", err)
+			errs[sitemapURL] = err
+			continue
+		}
+		entries = append(entries, found...)
+	}
+	return DedupeSitemapEntries(entries, s.IgnoreTrailingSlash), errs
+}
+
+// parseSitemapDepth fetches one sitemap document and returns its page entries,
+// descending into child sitemaps when the document is a sitemap index.
+// visited records every sitemap already fetched so cycles are not followed.
+func (s *Scraper) parseSitemapDepth(sitemapURL string, depth int, visited map[string]bool) ([]SitemapEntry, error) {
+	if visited[sitemapURL] {
+		return nil, nil
+	}
+	visited[sitemapURL] = true
+
+	// Only the sitemap the caller names may be a local file. Locations taken
+	// from a fetched sitemap index must not reach the filesystem.
+	body, encoding, err := s.readSitemap(sitemapURL, depth == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sitemaps are often published gzipped (.xml.gz), either flagged by the
+	// Content-Encoding header or only recognisable by the gzip magic bytes
+	if encoding == "gzip" || isGzip(body) {
+		body, err = gunzip(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := rootElement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A sitemap index lists other sitemaps, so fetch each of them in turn
+	if root == "sitemapindex" {
+		if depth >= maxSitemapDepth {
+			return nil, fmt.Errorf("sitemap index %s exceeds max depth %d", sitemapURL, maxSitemapDepth)
+		}
+
+		var index SitemapIndex
+		if err := decodeXML(body, &index); err != nil {
+			return nil, err
+		}
+
+		var entries []SitemapEntry
+		for _, child := range index.Sitemaps {
+			loc := strings.TrimSpace(child.Loc)
+			if loc == "" {
+				continue
+			}
+			childEntries, err := s.parseSitemapDepth(loc, depth+1, visited)
+			if err != nil {
+				s.log().Warn("Error parsing child sitemap", "url", loc, "err", err)
+				continue
+			}
+			entries = append(entries, childEntries...)
+		}
+		return entries, nil
+	}
+
+	var sitemap Sitemap
+	err = decodeXML(body, &sitemap)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []SitemapEntry
+	for _, url := range sitemap.Urls {
+		entry := SitemapEntry{
+			Loc:        strings.TrimSpace(url.Loc),
+			LastMod:    strings.TrimSpace(url.LastMod),
+			ChangeFreq: strings.TrimSpace(url.ChangeFreq),
+			Priority:   defaultSitemapPriority,
+		}
+		if priority, err := strconv.ParseFloat(strings.TrimSpace(url.Priority), 64); err == nil {
+			entry.Priority = priority
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// maxSitemapSize is the largest sitemap the sitemaps protocol allows, before
+// or after decompression
+const maxSitemapSize = 50 << 20
+
+// readSitemap returns the contents of the sitemap at sitemapURL and the
+// Content-Encoding it was served with. When allowLocal is set, file:// URLs
+// and plain paths are read from disk so saved sitemaps can be reprocessed
+// offline; otherwise only http and https URLs are accepted.
+func (s *Scraper) readSitemap(sitemapURL string, allowLocal bool) ([]byte, string, error) {
+	if path, ok := localPath(sitemapURL); ok && allowLocal {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+		body, err := readLimited(file)
+		return body, "", err
+	}
+	if !isHTTPURL(sitemapURL) {
+		return nil, "", fmt.Errorf("sitemap %q is not an http or https URL", sitemapURL)
+	}
+
+	resp, err := s.makeRequest(context.Background(), sitemapURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, resp.Header.Get("Content-Encoding"), nil
+}
+
+// readLimited reads all of r, failing once it passes maxSitemapSize
+func readLimited(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxSitemapSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSitemapSize {
+		return nil, fmt.Errorf("sitemap is larger than %d bytes", maxSitemapSize)
+	}
+	return body, nil
+}
+
+// isHTTPURL reports whether location is an absolute http or https URL
+func isHTTPURL(location string) bool {
+	parsed, err := url.Parse(location)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// localPath returns the file path named by a file:// URL or by a string
+// without a URL scheme
+func localPath(location string) (string, bool) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return "", false
+	}
+	switch parsed.Scheme {
+	case "":
+		return location, true
+	case "file":
+		return parsed.Path, true
+	}
+	return "", false
+}
+
+// newXMLDecoder returns a decoder for body that also understands documents
+// declaring a non-UTF-8 encoding such as ISO-8859-1
+func newXMLDecoder(body []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder
+}
+
+// decodeXML unmarshals the XML document in body into v
+func decodeXML(body []byte, v any) error {
+	return newXMLDecoder(body).Decode(v)
+}
+
+// rootElement returns the local name of the first element in an XML document
+func rootElement(body []byte) (string, error) {
+	decoder := newXMLDecoder(body)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// isGzip reports whether data starts with the gzip magic bytes
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// gunzip decompresses a gzip encoded body, refusing to expand it past
+// maxSitemapSize
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readLimited(reader)
+}
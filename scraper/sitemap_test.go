@@ -0,0 +1,310 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// urlset returns a sitemap listing locs
+func urlset(locs ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	for _, loc := range locs {
+		fmt.Fprintf(&b, "<url><loc>%s</loc></url>\n", loc)
+	}
+	b.WriteString("</urlset>\n")
+	return b.String()
+}
+
+// serveFiles starts a server answering each path in files with its contents
+// and anything else with 404
+func serveFiles(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParseSitemapIndex(t *testing.T) {
+	files := map[string]string{}
+	server := serveFiles(t, files)
+	files["/sitemap.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>` + server.URL + `/news.xml</loc></sitemap>
+  <sitemap><loc>` + server.URL + `/sports.xml</loc></sitemap>
+  <sitemap><loc>` + server.URL + `/missing.xml</loc></sitemap>
+</sitemapindex>`
+	files["/news.xml"] = urlset("https://example.com/news/1", "https://example.com/news/2")
+	files["/sports.xml"] = urlset("https://example.com/sports/1")
+
+	urls, err := (&Scraper{}).ParseSitemap(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+	want := []string{"https://example.com/news/1", "https://example.com/news/2", "https://example.com/sports/1"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("ParseSitemap = %q, want %q", urls, want)
+	}
+}
+
+func TestParseSitemapIndexWhitespace(t *testing.T) {
+	files := map[string]string{}
+	server := serveFiles(t, files)
+	files["/sitemap.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>
+      ` + server.URL + `/news.xml
+    </loc>
+  </sitemap>
+  <sitemap><loc>  </loc></sitemap>
+</sitemapindex>`
+	files["/news.xml"] = urlset("https://example.com/news/1")
+
+	urls, err := (&Scraper{}).ParseSitemap(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+	want := []string{"https://example.com/news/1"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("ParseSitemap = %q, want %q", urls, want)
+	}
+}
+
+func TestParseGzipSitemap(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(urlset("https://example.com/a", "https://example.com/b")))
+	zw.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	urls, err := (&Scraper{}).ParseSitemap(server.URL + "/sitemap.xml.gz")
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+	want := []string{"https://example.com/a", "https://example.com/b"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("ParseSitemap = %q, want %q", urls, want)
+	}
+}
+
+func TestParseSitemapStatus(t *testing.T) {
+	server := serveFiles(t, map[string]string{})
+
+	_, err := (&Scraper{}).ParseSitemap(server.URL + "/sitemap.xml")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("ParseSitemap of a missing sitemap = %v, want a 404 StatusError", err)
+	}
+}
+
+func TestParseSitemapTooLarge(t *testing.T) {
+	// A tiny gzip body that expands past the protocol's 50MB limit
+	var compressed bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&compressed, gzip.BestSpeed)
+	zw.Write(make([]byte, maxSitemapSize+1))
+	zw.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	if _, err := (&Scraper{}).ParseSitemap(server.URL + "/sitemap.xml.gz"); err == nil {
+		t.Error("ParseSitemap of a sitemap expanding past the size limit returned no error")
+	}
+}
+
+func TestParseSitemapEntries(t *testing.T) {
+	server := serveFiles(t, map[string]string{"/sitemap.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/old</loc><lastmod>2024-01-15</lastmod><priority>0.3</priority></url>
+  <url><loc>https://example.com/none</loc></url>
+  <url><loc>https://example.com/new</loc><lastmod>2026-10-01T08:30:00+02:00</lastmod><changefreq>daily</changefreq><priority>0.9</priority></url>
+  <url><loc>https://example.com/top</loc><lastmod>2025-06</lastmod><priority>1.0</priority></url>
+</urlset>`})
+
+	entries, err := (&Scraper{}).ParseSitemapEntries(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ParseSitemapEntries: %v", err)
+	}
+	want := []SitemapEntry{
+		{Loc: "https://example.com/old", LastMod: "2024-01-15", Priority: 0.3},
+		{Loc: "https://example.com/none", Priority: 0.5},
+		{Loc: "https://example.com/new", LastMod: "2026-10-01T08:30:00+02:00", ChangeFreq: "daily", Priority: 0.9},
+		{Loc: "https://example.com/top", LastMod: "2025-06", Priority: 1.0},
+	}
+	if !slices.Equal(entries, want) {
+		t.Fatalf("ParseSitemapEntries = %+v, want %+v", entries, want)
+	}
+
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{"", []string{"/old", "/none", "/new", "/top"}},
+		{"priority", []string{"/top", "/new", "/none", "/old"}},
+		// Entries without a lastmod go last
+		{"lastmod", []string{"/new", "/top", "/old", "/none"}},
+	}
+	for _, tt := range tests {
+		sorted := slices.Clone(entries)
+		SortSitemapEntries(sorted, tt.by)
+		var paths []string
+		for _, loc := range SitemapLocs(sorted) {
+			paths = append(paths, strings.TrimPrefix(loc, "https://example.com"))
+		}
+		if !slices.Equal(paths, tt.want) {
+			t.Errorf("sorted by %q = %q, want %q", tt.by, paths, tt.want)
+		}
+	}
+}
+
+func TestParseLocalSitemap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sitemap.xml")
+	if err := os.WriteFile(path, []byte(urlset("https://example.com/a", "https://example.com/b")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://example.com/a", "https://example.com/b"}
+
+	for _, location := range []string{path, "file://" + path} {
+		urls, err := (&Scraper{}).ParseSitemap(location)
+		if err != nil {
+			t.Errorf("ParseSitemap(%q): %v", location, err)
+			continue
+		}
+		if !slices.Equal(urls, want) {
+			t.Errorf("ParseSitemap(%q) = %q, want %q", location, urls, want)
+		}
+	}
+
+	if _, err := (&Scraper{}).ParseSitemap(filepath.Join(t.TempDir(), "missing.xml")); err == nil {
+		t.Error("ParseSitemap of a missing file returned no error")
+	}
+}
+
+func TestParseSitemapIndexLocalChild(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sitemap.xml")
+	if err := os.WriteFile(path, []byte(urlset("https://example.com/secret")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A remote index must not be able to make the scraper read local files
+	server := serveFiles(t, map[string]string{"/sitemap.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>` + path + `</loc></sitemap>
+  <sitemap><loc>file://` + path + `</loc></sitemap>
+</sitemapindex>`})
+
+	urls, err := (&Scraper{}).ParseSitemap(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+	if len(urls) != 0 {
+		t.Errorf("ParseSitemap = %q, want no URLs from local child sitemaps", urls)
+	}
+}
+
+func TestParseSitemapNamespaces(t *testing.T) {
+	files := map[string]string{}
+	server := serveFiles(t, files)
+	files["/default.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url><loc>https://example.com/a</loc><image:image><image:loc>https://example.com/a.png</image:loc></image:image></url>
+  <url><loc>
+    https://example.com/b
+  </loc></url>
+</urlset>`
+	files["/prefixed.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<sm:urlset xmlns:sm="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sm:url><sm:loc>https://example.com/a</sm:loc></sm:url>
+  <sm:url><sm:loc>https://example.com/b</sm:loc></sm:url>
+</sm:urlset>`
+	files["/bare.xml"] = `<urlset><url><loc>https://example.com/a</loc></url><url><loc>https://example.com/b</loc></url></urlset>`
+	files["/index.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<sm:sitemapindex xmlns:sm="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sm:sitemap><sm:loc>` + server.URL + `/prefixed.xml</sm:loc></sm:sitemap>
+</sm:sitemapindex>`
+
+	want := []string{"https://example.com/a", "https://example.com/b"}
+	for _, name := range []string{"/default.xml", "/prefixed.xml", "/bare.xml", "/index.xml"} {
+		urls, err := (&Scraper{}).ParseSitemap(server.URL + name)
+		if err != nil {
+			t.Errorf("ParseSitemap(%s): %v", name, err)
+			continue
+		}
+		if !slices.Equal(urls, want) {
+			t.Errorf("ParseSitemap(%s) = %q, want %q", name, urls, want)
+		}
+	}
+}
+
+func TestDedupeSitemap(t *testing.T) {
+	server := serveFiles(t, map[string]string{"/sitemap.xml": urlset(
+		"https://example.com/a",
+		"http://example.com/a",
+		"https://EXAMPLE.com/a",
+		"https://example.com/a/",
+		"https://example.com",
+		"https://example.com/",
+		"https://example.com/a?page=2",
+		"https://example.com/A",
+		"https://example.com/a",
+	)})
+
+	tests := []struct {
+		trimSlash bool
+		want      []string
+	}{
+		{false, []string{"https://example.com/a", "https://example.com/a/", "https://example.com", "https://example.com/a?page=2", "https://example.com/A"}},
+		{true, []string{"https://example.com/a", "https://example.com", "https://example.com/a?page=2", "https://example.com/A"}},
+	}
+	for _, tt := range tests {
+		urls, err := (&Scraper{IgnoreTrailingSlash: tt.trimSlash}).ParseSitemap(server.URL + "/sitemap.xml")
+		if err != nil {
+			t.Fatalf("ParseSitemap: %v", err)
+		}
+		if !slices.Equal(urls, tt.want) {
+			t.Errorf("IgnoreTrailingSlash %v: ParseSitemap = %q, want %q", tt.trimSlash, urls, tt.want)
+		}
+	}
+}
+
+func TestMergeSitemaps(t *testing.T) {
+	server := serveFiles(t, map[string]string{
+		"/news.xml":   urlset("https://example.com/news/1", "https://example.com/shared"),
+		"/sports.xml": urlset("https://example.com/sports/1", "http://example.com/shared"),
+	})
+	news, sports, missing := server.URL+"/news.xml", server.URL+"/sports.xml", server.URL+"/missing.xml"
+
+	entries, errs := (&Scraper{}).MergeSitemaps([]string{news, missing, sports, news})
+	want := []string{"https://example.com/news/1", "https://example.com/shared", "https://example.com/sports/1"}
+	if got := SitemapLocs(entries); !slices.Equal(got, want) {
+		t.Errorf("MergeSitemaps = %q, want %q", got, want)
+	}
+	if len(errs) != 1 || errs[missing] == nil {
+		t.Errorf("MergeSitemaps errors = %v, want one for %s", errs, missing)
+	}
+}
@@ -0,0 +1,101 @@
+package scraper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Summary holds the totals reported at the end of a run
+type Summary struct {
+	Pages        int
+	Images       int
+	UniqueImages int
+	Failed       int
+	// Skipped counts the URLs not scraped because robots.txt disallowed
+	// them, their host's circuit breaker was open, or the run was stopped
+	// early; they aren't included in Failed
+	Skipped int
+	// Causes counts the failed URLs by the category of their error
+	Causes map[ErrorCategory]int
+	// DuplicatePages counts pages flagged with DuplicateOf
+	DuplicatePages int
+	// BrokenImages counts the unique images that VerifyImages found broken
+	BrokenImages int
+
+	// verified is set once a page with checked images has been counted
+	verified bool
+
+	// seen holds the image URLs counted so far
+	seen map[string]bool
+}
+
+// Add counts one more page and its images
+func (s *Summary) Add(res MediaData) {
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.Pages++
+	s.Images += len(res.ImageURLs)
+	if res.DuplicateOf != "" {
+		s.DuplicatePages++
+	}
+	if res.ImageStatuses != nil {
+		s.verified = true
+	}
+	for _, image := range res.ImageURLs {
+		if status, ok := res.ImageStatuses[image]; ok && !s.seen[image] && ImageBroken(status) {
+			s.BrokenImages++
+		}
+		s.seen[image] = true
+	}
+	s.UniqueImages = len(s.seen)
+}
+
+// AddErrors counts the URLs in errs as failed, by cause, or as skipped when
+// they were left out on purpose
+func (s *Summary) AddErrors(errs map[string]error) {
+	for _, err := range errs {
+		category := Categorize(err)
+		if category == CategorySkipped {
+			s.Skipped++
+			continue
+		}
+		if s.Causes == nil {
+			s.Causes = make(map[ErrorCategory]int)
+		}
+		s.Failed++
+		s.Causes[category]++
+	}
+}
+
+// ImagesPerPage is the average number of images found on a page
+func (s Summary) ImagesPerPage() float64 {
+	if s.Pages == 0 {
+		return 0
+	}
+	return float64(s.Images) / float64(s.Pages)
+}
+
+// String formats the summary as a single line. The broken image count is
+// only included when images were verified, and the skipped URL count and
+// failure causes when there were any.
+func (s Summary) String() string {
+	line := fmt.Sprintf("Pages: %d, images: %d, unique images: %d, failed URLs: %d, images per page: %.1f, duplicate pages: %d",
+		s.Pages, s.Images, s.UniqueImages, s.Failed, s.ImagesPerPage(), s.DuplicatePages)
+	if len(s.Causes) > 0 {
+		var causes []string
+		for category, count := range s.Causes {
+			causes = append(causes, fmt.Sprintf("%s %d", category, count))
+		}
+		sort.Strings(causes)
+		line += ", failed by cause: " + strings.Join(causes, ", ")
+	}
+	if s.Skipped > 0 {
+		line += fmt.Sprintf(", skipped URLs: %d", s.Skipped)
+	}
+	if s.verified {
+		line += fmt.Sprintf(", broken images: %d", s.BrokenImages)
+	}
+	return line
+}
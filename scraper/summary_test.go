@@ -0,0 +1,43 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	var summary Summary
+	for _, res := range []MediaData{
+		{URL: "https://example.com/1", ImageURLs: []string{"logo.png", "a.jpg", "b.jpg"}},
+		{URL: "https://example.com/2", ImageURLs: []string{"logo.png", "c.jpg"}},
+		{URL: "https://example.com/3", ImageURLs: []string{}},
+		{URL: "https://example.com/4", ImageURLs: []string{"logo.png"}},
+	} {
+		summary.Add(res)
+	}
+	if summary.Pages != 4 || summary.Images != 6 || summary.UniqueImages != 4 {
+		t.Errorf("Pages, Images, UniqueImages = %d, %d, %d, want 4, 6, 4", summary.Pages, summary.Images, summary.UniqueImages)
+	}
+	if got := summary.ImagesPerPage(); got != 1.5 {
+		t.Errorf("ImagesPerPage = %v, want 1.5", got)
+	}
+	want := "Pages: 4, images: 6, unique images: 4, failed URLs: 0, images per page: 1.5, duplicate pages: 0"
+	if got := summary.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	summary.AddErrors(map[string]error{
+		"https://example.com/5": errors.New("connection reset"),
+		"https://example.com/6": &StatusError{StatusCode: 404},
+	})
+	if summary.Failed != 2 {
+		t.Errorf("Failed = %d, want 2", summary.Failed)
+	}
+}
+
+func TestSummaryEmpty(t *testing.T) {
+	var summary Summary
+	if got := summary.ImagesPerPage(); got != 0 {
+		t.Errorf("ImagesPerPage of no pages = %v, want 0", got)
+	}
+}
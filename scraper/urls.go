@@ -0,0 +1,86 @@
+package scraper
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ReadURLsFile reads newline separated URLs from a file, ignoring blank lines
+// and lines starting with #
+func ReadURLsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// MergeURLs concatenates URL lists, dropping repeats while keeping the order
+// in which URLs were first seen
+func MergeURLs(lists ...[]string) []string {
+	var merged []string
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	return dedupeStrings(merged)
+}
+
+// LimitURLs returns at most n URLs. It takes the first n, or a random sample
+// of n (kept in their original order) when sample is set. n <= 0 means no
+// limit.
+func LimitURLs(urls []string, n int, sample bool) []string {
+	if n <= 0 || n >= len(urls) {
+		return urls
+	}
+	if !sample {
+		return urls[:n]
+	}
+
+	rngMu.Lock()
+	picked := rng.Perm(len(urls))[:n]
+	rngMu.Unlock()
+	sort.Ints(picked)
+
+	sampled := make([]string, 0, n)
+	for _, i := range picked {
+		sampled = append(sampled, urls[i])
+	}
+	return sampled
+}
+
+// FilterURLs keeps the URLs matching include (when set) and not matching
+// exclude (when set). A URL matching both is dropped.
+func FilterURLs(urls []string, include, exclude *regexp.Regexp) []string {
+	if include == nil && exclude == nil {
+		return urls
+	}
+
+	kept := []string{}
+	for _, url := range urls {
+		if exclude != nil && exclude.MatchString(url) {
+			continue
+		}
+		if include != nil && !include.MatchString(url) {
+			continue
+		}
+		kept = append(kept, url)
+	}
+	return kept
+}
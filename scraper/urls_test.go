@@ -0,0 +1,95 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestReadURLsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	contents := "# seed pages\nhttps://example.com/a\n\n  https://example.com/b  \r\n   # indented comment\nhttps://example.com/c"
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	urls, err := ReadURLsFile(path)
+	if err != nil {
+		t.Fatalf("ReadURLsFile: %v", err)
+	}
+	want := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("ReadURLsFile = %q, want %q", urls, want)
+	}
+
+	if _, err := ReadURLsFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("ReadURLsFile of a missing file returned no error")
+	}
+}
+
+func TestMergeURLs(t *testing.T) {
+	got := MergeURLs([]string{"https://example.com/a", "https://example.com/b"}, []string{"https://example.com/b", "https://example.com/c"})
+	want := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+	if !slices.Equal(got, want) {
+		t.Errorf("MergeURLs = %q, want %q", got, want)
+	}
+}
+
+func TestLimitURLs(t *testing.T) {
+	var urls []string
+	for i := 0; i < 20; i++ {
+		urls = append(urls, fmt.Sprintf("https://example.com/%02d", i))
+	}
+
+	if got := LimitURLs(urls, 5, false); !slices.Equal(got, urls[:5]) {
+		t.Errorf("LimitURLs(20 URLs, 5) = %q, want the first 5", got)
+	}
+	for _, n := range []int{0, -1, 20, 50} {
+		if got := LimitURLs(urls, n, false); len(got) != len(urls) {
+			t.Errorf("LimitURLs(20 URLs, %d) gave %d URLs, want all 20", n, len(got))
+		}
+	}
+
+	sample := LimitURLs(urls, 5, true)
+	if len(sample) != 5 {
+		t.Fatalf("sample has %d URLs, want 5", len(sample))
+	}
+	// The sample keeps the original order, so it is sorted like urls
+	if !slices.IsSorted(sample) || len(slices.Compact(slices.Clone(sample))) != 5 {
+		t.Errorf("sample = %q, want 5 distinct URLs in their original order", sample)
+	}
+	for _, url := range sample {
+		if !slices.Contains(urls, url) {
+			t.Errorf("sample contains %q, which isn't one of the URLs", url)
+		}
+	}
+}
+
+func TestFilterURLs(t *testing.T) {
+	urls := []string{
+		"https://example.com/news/1",
+		"https://example.com/news/2/amp",
+		"https://example.com/sports/1",
+		"https://example.com/about",
+	}
+	news := regexp.MustCompile(`/news/`)
+	amp := regexp.MustCompile(`/amp$`)
+	tests := []struct {
+		include, exclude *regexp.Regexp
+		want             []string
+	}{
+		{nil, nil, urls},
+		{news, nil, urls[:2]},
+		{nil, amp, []string{urls[0], urls[2], urls[3]}},
+		{news, amp, urls[:1]},
+		{regexp.MustCompile(`/weather/`), nil, []string{}},
+	}
+	for _, tt := range tests {
+		if got := FilterURLs(urls, tt.include, tt.exclude); !slices.Equal(got, tt.want) {
+			t.Errorf("FilterURLs(include %v, exclude %v) = %q, want %q", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
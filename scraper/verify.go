@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ImageBroken reports whether an image status recorded by VerifyImages
+// means the image can't be displayed
+func ImageBroken(status int) bool {
+	return status == 0 || status >= 400
+}
+
+// VerifyImages checks that every unique URL in imageURLs can be fetched and
+// returns the status code of each. A HEAD request is tried first and a GET
+// is sent instead when the server doesn't support HEAD or the HEAD request
+// fails; images that get no response at all have status 0. Inline data:
+// images aren't checked.
+func (s *Scraper) VerifyImages(ctx context.Context, imageURLs []string) map[string]int {
+	statuses := make(map[string]int)
+	var mu sync.Mutex
+	s.eachImage(imageURLs, func(imgURL string) {
+		status := s.imageStatus(ctx, imgURL)
+		mu.Lock()
+		statuses[imgURL] = status
+		mu.Unlock()
+	})
+	return statuses
+}
+
+// eachImage calls check once for every unique remote URL in imageURLs, from
+// the scraper's worker pool, and returns when all the calls are done
+func (s *Scraper) eachImage(imageURLs []string, check func(imgURL string)) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+
+	concurrency := s.boundConcurrency(s.Concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for imgURL := range jobs {
+				check(imgURL)
+			}
+		}()
+	}
+
+	// Check each image once, however many pages use it
+	for _, imgURL := range dedupeStrings(imageURLs) {
+		if !strings.HasPrefix(imgURL, "data:") {
+			jobs <- imgURL
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// imageStatus returns the status code imgURL is served with, or 0 when the
+// request fails
+func (s *Scraper) imageStatus(ctx context.Context, imgURL string) int {
+	resp, err := s.sendRequest(ctx, http.MethodHead, imgURL, nil)
+	if err == nil {
+		closeBody(resp)
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			return resp.StatusCode
+		}
+	}
+
+	// Some servers reject or mishandle HEAD, so ask for the image itself
+	resp, err = s.sendRequest(ctx, http.MethodGet, imgURL, nil)
+	if err != nil {
+		s.log().Info("Image could not be fetched", "url", imgURL, "err", err)
+		return 0
+	}
+	closeBody(resp)
+	return resp.StatusCode
+}
+
+// SetImageStatuses fills in res.ImageStatuses for the page's images from the
+// statuses returned by VerifyImages
+func SetImageStatuses(res *MediaData, statuses map[string]int) {
+	res.ImageStatuses = make(map[string]int)
+	for _, imgURL := range res.ImageURLs {
+		if status, ok := statuses[imgURL]; ok {
+			res.ImageStatuses[imgURL] = status
+		}
+	}
+}
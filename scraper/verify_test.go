@@ -0,0 +1,61 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestVerifyImages(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/ok.png":
+		case "/nohead.png":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ok, missing, noHead := server.URL+"/ok.png", server.URL+"/missing.png", server.URL+"/nohead.png"
+	refused := "http://127.0.0.1:1/refused.png"
+	inline := "data:image/gif;base64,R0lGODlhAQABAAAAACw="
+	s := &Scraper{Concurrency: 2}
+	statuses := s.VerifyImages(context.Background(), []string{ok, missing, ok, noHead, refused, inline})
+
+	want := map[string]int{ok: 200, missing: 404, noHead: 200, refused: 0}
+	if fmt.Sprint(statuses) != fmt.Sprint(want) {
+		t.Errorf("VerifyImages = %v, want %v", statuses, want)
+	}
+	wantRequests := map[string]int{
+		"HEAD /ok.png":      1,
+		"HEAD /missing.png": 1,
+		"HEAD /nohead.png":  1,
+		"GET /nohead.png":   1,
+	}
+	if fmt.Sprint(requests) != fmt.Sprint(wantRequests) {
+		t.Errorf("server got requests %v, want %v", requests, wantRequests)
+	}
+
+	res := MediaData{ImageURLs: []string{ok, missing, inline}}
+	SetImageStatuses(&res, statuses)
+	if fmt.Sprint(res.ImageStatuses) != fmt.Sprint(map[string]int{ok: 200, missing: 404}) {
+		t.Errorf("ImageStatuses = %v", res.ImageStatuses)
+	}
+	for status, broken := range map[int]bool{0: true, 200: false, 304: false, 404: true, 500: true} {
+		if ImageBroken(status) != broken {
+			t.Errorf("ImageBroken(%d) = %v, want %v", status, !broken, broken)
+		}
+	}
+}